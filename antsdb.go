@@ -2,22 +2,26 @@ package antsdb
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	ipfslite "github.com/hsanjuan/ipfs-lite"
 	"github.com/ipfs/go-datastore"
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
 	"github.com/ipfs/go-datastore/query"
 	crdt "github.com/ipfs/go-ds-crdt"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/routing"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
-	multihash "github.com/multiformats/go-multihash"
 	store "github.com/plexsysio/gkvstore"
 	dsStore "github.com/plexsysio/gkvstore-ipfsds"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -25,6 +29,14 @@ var (
 	defaultTopic  = "antWorker"
 	blocksNs      = "b"
 	log           = logging.Logger("antsdb")
+
+	defaultDAGTimeout = 2 * time.Minute
+	// go-ds-crdt uses the timeout for every context it derives, so zero
+	// would expire immediately. A century is as good as never.
+	noDAGTimeout = 100 * 365 * 24 * time.Hour
+
+	ErrConflictingDAGTimeout = errors.New("WithNoDAGTimeout and WithDAGSyncerTimeout are mutually exclusive")
+	ErrPubSubRequired        = errors.New("channel and peer validator options need pubsub")
 )
 
 type Option func(a *AntsDB)
@@ -53,6 +65,22 @@ func WithRebroadcastDuration(d time.Duration) Option {
 	}
 }
 
+func WithDAGSyncerTimeout(d time.Duration) Option {
+	return func(a *AntsDB) {
+		a.dagTimeout = d
+	}
+}
+
+// WithNoDAGTimeout makes the DAG syncer wait forever for missing blocks.
+// This is meant for closed clusters where every block is guaranteed to be
+// available. If a block is truly lost, processing of that branch stalls
+// permanently instead of failing.
+func WithNoDAGTimeout() Option {
+	return func(a *AntsDB) {
+		a.noDAGTimeout = true
+	}
+}
+
 func WithOnCloseHook(hook func()) Option {
 	return func(a *AntsDB) {
 		a.addOnClose(hook)
@@ -64,6 +92,13 @@ type Subscriber interface {
 	Delete(string)
 }
 
+// ClearSubscriber is notified once Clean has wiped all the data, instead of
+// receiving a Delete for every key.
+type ClearSubscriber interface {
+	Subscriber
+	Cleared()
+}
+
 func WithSubscriber(s Subscriber) Option {
 	return func(a *AntsDB) {
 		a.subscriber = s
@@ -80,20 +115,124 @@ func defaultOpts(a *AntsDB) {
 	if a.rebcastInterval == 0 {
 		a.rebcastInterval = time.Second
 	}
+	if a.ttlSweep == 0 {
+		a.ttlSweep = time.Minute
+	}
+	if a.tracer == nil {
+		a.tracer = trace.NewNoopTracerProvider().Tracer("antsdb")
+	}
+	if a.changeCodec == nil {
+		a.changeCodec = JSONChangeCodec{}
+	}
+}
+
+func verifyOpts(a *AntsDB) error {
+	if a.pubsub == nil && (len(a.topicName) != 0 || a.validator != nil) {
+		return ErrPubSubRequired
+	}
+	if len(a.encKeys) > 0 {
+		k, err := newKeyring(a.encKeys)
+		if err != nil {
+			return err
+		}
+		a.keyring = k
+	}
+	if a.noDAGTimeout && a.dagTimeout != 0 {
+		return ErrConflictingDAGTimeout
+	}
+	if a.subscriber != nil && a.retrySub != nil {
+		return ErrConflictingSubscribers
+	}
+	if a.hashKeys && a.maxKeyLen == 0 {
+		return ErrKeyHashingNoLimit
+	}
+	if a.noDAGTimeout {
+		a.dagTimeout = noDAGTimeout
+	}
+	if a.dagTimeout == 0 {
+		a.dagTimeout = defaultDAGTimeout
+	}
+	return nil
 }
 
 type AntsDB struct {
+	// Updated atomically, as are the counters of gc, so kept first for
+	// 64-bit alignment on 32-bit platforms
+	routines     int64
+	resources    int64
+	lastRemote   int64
+	watcherCount int64
+	gc           gcState
+
 	ctx             context.Context
 	cancel          context.CancelFunc
+	host            host.Host
 	syncer          crdt.SessionDAGService
 	pubsub          *pubsub.PubSub
 	storage         ds.Batching
 	namespace       ds.Key
 	subscriber      Subscriber
+	retrySub        RetryableSubscriber
+	subRetries      int
+	subBackoff      time.Duration
+	deadLetter      func(string, bool, error)
 	topicName       string
 	rebcastInterval time.Duration
+	dagTimeout      time.Duration
+	noDAGTimeout    bool
 	validator       func(context.Context, peer.ID) bool
+	ttlSweep        time.Duration
 	closers         []func()
+	crdt            *crdt.Datastore
+	local           *localDS
+	bcast           broadcaster
+	writers         *writers
+	maxQueued       int
+	packer          *packer
+	lazy            bool
+	codec           StoreCodec
+	hotPrefixes     []ds.Key
+	conflicts       *conflicts
+	encKeys         [][]byte
+	keyring         *keyring
+	rotating        sync.RWMutex
+	batching        sync.Mutex
+	collecting      *Token
+	collectMtx      sync.Mutex
+	blocks          blockstore.Blockstore
+	maxKeyLen       int
+	hashKeys        bool
+	syncWatch       syncWatch
+	changes         *changeLog
+	changeBacklog   int
+	changeCodec     ChangeCodec
+	mirrors         mirrors
+	allowMismatch   bool
+	bcastRate       int
+	deltaHook       func(AppliedDelta)
+	hookedDeltas    *lru.Cache
+	injector        *injector
+	dhtOptional     bool
+	dhtProbe        *optionalRouting
+	lastWriters     *lastWriters
+	sharedNs        bool
+	events          events
+	branches        branches
+	keyCount        keyCount
+	autoGC          *autoGC
+	maxWatchers     int64
+	eagerConnect    bool
+	tracer          trace.Tracer
+	watchers        watchers
+	storeBatchSize  int
+	storeFlush      time.Duration
+	buffered        *bufferedStore
+	staleAfter      time.Duration
+	staleAction     StaleAction
+	started         time.Time
+	stale           int32
+	hot             chan struct{}
+	wg              sync.WaitGroup
 
 	store.Store
 }
@@ -111,16 +250,45 @@ func New(
 	adb := &AntsDB{
 		ctx:     ctx,
 		cancel:  cancel,
+		host:    host,
 		pubsub:  pubsub,
 		storage: store,
+		writers: newWriters(),
+		started: time.Now(),
 	}
 	for _, opt := range opts {
 		opt(adb)
 	}
+	if err := verifyOpts(adb); err != nil {
+		cancel()
+		return nil, err
+	}
 	defaultOpts(adb)
+	if adb.autoGC != nil {
+		adb.autoGC.storage, _ = store.(ds.PersistentDatastore)
+	}
+	// Claimed on the backend, as instances sharing it have their own buffers
+	if err := adb.claimNamespace(); err != nil {
+		cancel()
+		return nil, err
+	}
+	if adb.storeBatchSize > 0 {
+		adb.buffered = newBufferedStore(store, adb.storeBatchSize)
+		adb.storage = adb.buffered
+	}
+	if err := adb.checkVersion(ctx); err != nil {
+		adb.releaseNamespace()
+		cancel()
+		return nil, err
+	}
 
 	blocksDatastore := namespace.Wrap(store, adb.namespace.ChildString(blocksNs))
 
+	if adb.dhtOptional && dht != nil {
+		adb.dhtProbe = &optionalRouting{Routing: dht, self: host.ID()}
+		dht = adb.dhtProbe
+	}
+
 	ipfs, err := ipfslite.New(
 		ctx,
 		blocksDatastore,
@@ -131,72 +299,158 @@ func New(
 		},
 	)
 	if err != nil {
+		adb.releaseNamespace()
 		cancel()
 		return nil, err
 	}
 
 	adb.syncer = ipfs
-	return adb, adb.setup()
-}
-
-func (a *AntsDB) setup() error {
-	topicHash, err := multihash.Sum([]byte(a.topicName), multihash.MD5, -1)
-	if err == nil {
-		log.Infof("Updating topic name with hash %s", topicHash)
-		a.topicName = topicHash.B58String()
-	}
-	if a.validator != nil {
-		err = a.pubsub.RegisterTopicValidator(
-			a.topicName,
-			func(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
-				return a.validator(ctx, p)
-			},
-		)
-		if err != nil {
-			log.Errorf("Failed registering pubsub topic Err:%s", err.Error())
-			return err
-		}
+	adb.blocks = ipfs.BlockStore()
+	adb.openResource()
+	if err := adb.setup(); err != nil {
+		adb.releaseNamespace()
+		cancel()
+		adb.wg.Wait()
+		return nil, err
 	}
-	broadcaster, err := crdt.NewPubSubBroadcaster(
-		a.ctx,
-		a.pubsub,
-		a.topicName,
-	)
+	adb.addOnClose(adb.releaseNamespace)
+	return adb, nil
+}
+
+func (a *AntsDB) setup() (err error) {
+	broadcaster, err := a.newBroadcaster()
 	if err != nil {
-		log.Errorf("Failed creating broadcaster Err:%s", err.Error())
 		return err
 	}
+	defer func() {
+		if err != nil {
+			broadcaster.Close()
+		}
+	}()
+	a.bcast = broadcaster
+	a.injector = newInjector(a, broadcaster)
 	opts := crdt.DefaultOptions()
 	opts.RebroadcastInterval = a.rebcastInterval
-	opts.DAGSyncerTimeout = 2 * time.Minute
+	opts.DAGSyncerTimeout = a.dagTimeout
 	opts.Logger = log
-	if a.subscriber != nil {
-		opts.PutHook = func(k ds.Key, v []byte) {
-			log.Infof("AntsDB PUT %s", k)
+	var retries *retrier
+	if a.retrySub != nil {
+		retries = newRetrier(a)
+		a.subscriber = retries
+	}
+	if a.changes != nil {
+		err := a.changes.load(a.ctx)
+		if err != nil {
+			return err
+		}
+	}
+	hooked := a.subscriber != nil || a.changes != nil || a.lastWriters != nil
+	opts.PutHook = func(k ds.Key, v []byte) {
+		a.matchWatchers(a.ctx, k.String(), v)
+		if !hooked {
+			return
+		}
+		log.Infof("AntsDB PUT %s", k)
+		if a.lastWriters != nil {
+			a.recordWriter(a.ctx, k.String(), v)
+		}
+		if a.changes != nil {
+			a.changes.record(k.String(), v, false)
+		}
+		if a.subscriber != nil {
 			a.subscriber.Put(k.String())
 		}
+	}
+	if hooked {
 		opts.DeleteHook = func(k ds.Key) {
 			log.Infof("AntsDB DELETE %s", k)
-			a.subscriber.Delete(k.String())
+			if a.lastWriters != nil {
+				a.forgetWriter(a.ctx, k.String())
+			}
+			if a.changes != nil {
+				a.changes.record(k.String(), nil, true)
+			}
+			if a.subscriber != nil {
+				a.subscriber.Delete(k.String())
+			}
 		}
 	}
+	a.conflicts = newConflicts(a.ctx)
 	crdt, err := crdt.New(
-		a.storage,
+		&eventStorage{Batching: a.storage, a: a},
 		a.namespace,
-		a.syncer,
-		broadcaster,
+		&crdtDAG{SessionDAGService: a.syncer, a: a},
+		a.injector,
 		opts,
 	)
 	if err != nil {
 		log.Errorf("Failed creating crdt datastore Err:%s", err.Error())
 		return err
 	}
-	a.Store = dsStore.New(crdt)
+	a.crdt = crdt
+	a.local = &localDS{Datastore: crdt, a: a}
+	a.openResource()
+	a.Store = dsStore.New(a.local)
+	if a.codec != nil {
+		a.Store = &codecStore{Store: a.Store, codec: a.codec}
+	}
+	if a.staleAfter > 0 {
+		a.Store = &staleStore{Store: a.Store, a: a}
+	}
+	a.spawn(a.sweepExpired)
+	if retries != nil {
+		a.spawn(retries.run)
+	}
+	if a.keyring != nil {
+		a.resumeRotation()
+	}
+	if a.changes != nil {
+		a.spawn(a.changes.run)
+	}
+	if a.packer != nil {
+		a.spawn(a.flushPackedValues)
+	}
+	if len(a.hotPrefixes) > 0 {
+		a.spawn(a.hotRebroadcast)
+	}
+	if a.dhtProbe != nil {
+		a.spawn(a.probeDHT)
+	}
+	if a.buffered != nil && a.storeFlush > 0 {
+		a.spawn(a.flushStorage)
+	}
+	if a.staleAfter > 0 {
+		a.spawn(a.watchStaleness)
+	}
+	if a.autoGC != nil {
+		a.spawn(a.runAutoGC)
+	}
+	a.host.SetStreamHandler(InfoProtocol, a.handleInfo)
+	a.host.SetStreamHandler(ReadProtocol, a.handleRead)
 	a.addOnClose(func() {
 		log.Info("Stopping AntsDB")
+		a.host.RemoveStreamHandler(InfoProtocol)
+		a.host.RemoveStreamHandler(ReadProtocol)
+		if a.packer != nil {
+			err := a.flushPacked(context.Background())
+			if err != nil {
+				log.Errorf("Failed flushing packed values Err:%s", err.Error())
+			}
+		}
 		a.cancel()
+		a.wg.Wait()
+		// ipfs-lite peer shuts down with the context
+		a.closeResource()
 		log.Info("Closing CRDT datastore")
 		crdt.Close()
+		if a.buffered != nil {
+			err := a.buffered.flush(context.Background())
+			if err != nil {
+				log.Errorf("Failed flushing storage writes Err:%s", err.Error())
+			}
+		}
+		a.closeResource()
+		broadcaster.Close()
 	})
 	return nil
 }
@@ -217,8 +471,33 @@ func (a *AntsDB) Close() error {
 	return nil
 }
 
-func (a *AntsDB) Clean(ctx context.Context) error {
+// Clean removes all AntsDB data from the storage. WithProgress reports the
+// keys removed out of all the keys under the namespace.
+func (a *AntsDB) Clean(ctx context.Context, opts ...OpOption) error {
+	ctx, span := a.startSpan(ctx, "Clean")
+	err := a.clean(ctx, opts...)
+	endSpan(span, err)
+	return err
+}
+
+func (a *AntsDB) clean(ctx context.Context, opts ...OpOption) error {
 	log.Info("cleaning all antsDB data")
+	cfg := newOpConfig(opts)
+	total := -1
+	if cfg.progress != nil {
+		count, err := a.countKeys(ctx, a.namespace.String())
+		if err != nil {
+			return err
+		}
+		total = count
+	}
+	prog := cfg.tracker(total)
+	if a.packer != nil {
+		a.packer.mtx.Lock()
+		a.packer.pending = make(map[string][]byte)
+		a.packer.size = 0
+		a.packer.mtx.Unlock()
+	}
 	q := query.Query{
 		Prefix:   a.namespace.String(),
 		KeysOnly: true,
@@ -238,6 +517,15 @@ func (a *AntsDB) Clean(ctx context.Context) error {
 		if err != nil {
 			log.Error(err)
 		}
+		prog.step()
+	}
+	prog.finish()
+	var sub interface{} = a.subscriber
+	if r, ok := sub.(*retrier); ok {
+		sub = r.sub
+	}
+	if cs, ok := sub.(interface{ Cleared() }); ok {
+		cs.Cleared()
 	}
 	return nil
 }
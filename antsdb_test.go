@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,7 +26,7 @@ import (
 	store "github.com/plexsysio/gkvstore"
 )
 
-func makeTestingHost(t *testing.T, opts ...Option) (*AntsDB, host.Host) {
+func makeTestingHost(t testing.TB, opts ...Option) (*AntsDB, host.Host) {
 	ctx, cancel := context.WithCancel(context.Background())
 	h, err := libp2p.New(
 		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
@@ -60,8 +61,9 @@ func makeTestingHost(t *testing.T, opts ...Option) (*AntsDB, host.Host) {
 
 	bs := syncds.MutexWrap(datastore.NewMapDatastore())
 
+	// Tests may override the rebroadcast interval
+	opts = append([]Option{WithRebroadcastDuration(time.Second)}, opts...)
 	opts = append(opts,
-		WithRebroadcastDuration(time.Second),
 		WithOnCloseHook(func() {
 			cancel()
 			log.Info("Stopping host")
@@ -103,7 +105,7 @@ func makeTestingHost(t *testing.T, opts ...Option) (*AntsDB, host.Host) {
 	return adb, h
 }
 
-func connectHosts(t *testing.T, hosts ...host.Host) {
+func connectHosts(t testing.TB, hosts ...host.Host) {
 	for i, h1 := range hosts {
 		rest := []host.Host{}
 		for j, h2 := range hosts {
@@ -493,3 +495,117 @@ func TestMultiHostList(t *testing.T) {
 		t.Fatalf("count mismatch during list")
 	}
 }
+
+func TestDAGTimeoutOptions(t *testing.T) {
+	_, err := New(nil, nil, nil, nil, WithNoDAGTimeout(), WithDAGSyncerTimeout(time.Second))
+	if err != ErrConflictingDAGTimeout {
+		t.Fatal("expected conflicting options error", err)
+	}
+
+	adb, _ := makeTestingHost(t, WithNoDAGTimeout())
+	defer adb.Close()
+
+	if adb.dagTimeout != noDAGTimeout {
+		t.Fatal("DAG timeout not disabled", adb.dagTimeout)
+	}
+	err = adb.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type clearSubscriber struct {
+	mtx     sync.Mutex
+	puts    int
+	deletes int
+	cleared int
+}
+
+func (c *clearSubscriber) Put(string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.puts++
+}
+
+func (c *clearSubscriber) Delete(string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.deletes++
+}
+
+func (c *clearSubscriber) Cleared() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.cleared++
+}
+
+func TestClearedSubscriber(t *testing.T) {
+	sub := &clearSubscriber{}
+	adb, _ := makeTestingHost(t, WithSubscriber(sub))
+	defer adb.Close()
+
+	for i := 0; i < 5; i++ {
+		err := adb.Put(context.TODO(), fmt.Sprintf("key/%d", i), []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := adb.Clean(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub.mtx.Lock()
+	defer sub.mtx.Unlock()
+	if sub.puts != 5 {
+		t.Fatal("incorrect put notifications", sub.puts)
+	}
+	if sub.cleared != 1 || sub.deletes != 0 {
+		t.Fatal("expected a single clear notification", sub.cleared, sub.deletes)
+	}
+}
+
+func TestLocalOnly(t *testing.T) {
+	h, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	idht, err := dual.New(context.TODO(), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idht.Close()
+
+	bs := syncds.MutexWrap(datastore.NewMapDatastore())
+
+	_, err = New(h, idht, nil, bs, WithChannel("ant1"))
+	if err != ErrPubSubRequired {
+		t.Fatal("expected error for channel without pubsub", err)
+	}
+
+	adb, err := New(h, idht, nil, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adb.Close()
+
+	err = adb.Put(context.TODO(), "local", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := adb.Get(context.TODO(), "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value", string(val))
+	}
+}
@@ -0,0 +1,61 @@
+// Package antsdbtest has helpers for testing code built on AntsDB.
+package antsdbtest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	antsdb "github.com/plexsysio/ants-db"
+)
+
+// ReplayRecorder captures the deltas an AntsDB instance applies, so the same
+// sequence can be applied to a fresh instance to reproduce convergence
+// issues. A captured trace can be saved and attached to bug reports.
+type ReplayRecorder struct {
+	mtx    sync.Mutex
+	deltas []antsdb.AppliedDelta
+}
+
+func NewReplayRecorder() *ReplayRecorder {
+	return &ReplayRecorder{}
+}
+
+// Option is passed to antsdb.New for the instance being recorded
+func (r *ReplayRecorder) Option() antsdb.Option {
+	return antsdb.WithDeltaHook(r.record)
+}
+
+func (r *ReplayRecorder) record(d antsdb.AppliedDelta) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.deltas = append(r.deltas, d)
+}
+
+func (r *ReplayRecorder) Deltas() []antsdb.AppliedDelta {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return append([]antsdb.AppliedDelta{}, r.deltas...)
+}
+
+// ReplayInto applies the recorded deltas to dest in the recorded order
+func (r *ReplayRecorder) ReplayInto(dest *antsdb.AntsDB) error {
+	return dest.ApplyDeltas(context.Background(), r.Deltas())
+}
+
+func (r *ReplayRecorder) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r.Deltas())
+}
+
+// LoadReplay reads a trace written by Save
+func LoadReplay(rd io.Reader) (*ReplayRecorder, error) {
+	r := NewReplayRecorder()
+	err := json.NewDecoder(rd).Decode(&r.deltas)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
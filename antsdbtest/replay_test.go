@@ -0,0 +1,83 @@
+package antsdbtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p"
+	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
+	antsdb "github.com/plexsysio/ants-db"
+)
+
+func newLocal(t *testing.T, opts ...antsdb.Option) *antsdb.AntsDB {
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idht, err := dual.New(context.TODO(), h)
+	if err != nil {
+		h.Close()
+		t.Fatal(err)
+	}
+	opts = append(opts, antsdb.WithOnCloseHook(func() {
+		idht.Close()
+		h.Close()
+	}))
+	adb, err := antsdb.New(h, idht, nil, syncds.MutexWrap(datastore.NewMapDatastore()), opts...)
+	if err != nil {
+		idht.Close()
+		h.Close()
+		t.Fatal(err)
+	}
+	return adb
+}
+
+func TestReplay(t *testing.T) {
+	rec := NewReplayRecorder()
+	src := newLocal(t, rec.Option())
+	defer src.Close()
+
+	for i := 0; i < 5; i++ {
+		err := src.Put(context.TODO(), fmt.Sprintf("key/%d", i%3), []byte(fmt.Sprintf("val/%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := src.DeleteKey(context.TODO(), "key/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Deltas()) == 0 {
+		t.Fatal("no deltas recorded")
+	}
+
+	// Traces survive a round trip through a bug report
+	buf := &bytes.Buffer{}
+	err = rec.Save(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadReplay(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := newLocal(t)
+	defer dest.Close()
+
+	err = loaded.ReplayInto(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"key/0", "key/1", "key/2"} {
+		want, wantErr := src.Get(context.TODO(), k)
+		got, gotErr := dest.Get(context.TODO(), k)
+		if wantErr != gotErr || !bytes.Equal(want, got) {
+			t.Fatal("replica diverged after replay", k, string(got), gotErr)
+		}
+	}
+}
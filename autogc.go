@@ -0,0 +1,84 @@
+package antsdb
+
+import (
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+type autoGC struct {
+	threshold float64
+	interval  time.Duration
+	storage   ds.PersistentDatastore
+	// usage right after the last GC, or at startup
+	baseline uint64
+}
+
+// WithAutoGC checks the disk usage of the storage every interval and runs
+// GC once it has grown by more than threshold since the previous GC, or
+// since startup: with a threshold of 0.5, GC runs whenever the storage is
+// half as large again as it was after the last collection. Storage which
+// does not report its disk usage, like an in-memory datastore, is never
+// collected automatically.
+//
+// Automatic runs follow the same rules as calling GC: deltas and pinned
+// values are kept, and a value block is only removed once two consecutive
+// runs found it unreferenced. A run is also skipped while heads announced
+// by peers are still being merged, as their deltas may reference values
+// this node already holds. Stats reports the runs and blocks removed.
+func WithAutoGC(threshold float64, interval time.Duration) Option {
+	return func(a *AntsDB) {
+		a.autoGC = &autoGC{threshold: threshold, interval: interval}
+	}
+}
+
+func (a *AntsDB) runAutoGC() {
+	g := a.autoGC
+	if g.storage == nil {
+		log.Warn("Storage does not report its disk usage, automatic GC disabled")
+		return
+	}
+	var err error
+	g.baseline, err = g.storage.DiskUsage(a.ctx)
+	if err != nil {
+		log.Errorf("Failed reading disk usage Err:%s", err.Error())
+	}
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		usage, err := g.storage.DiskUsage(a.ctx)
+		if err != nil {
+			log.Errorf("Failed reading disk usage Err:%s", err.Error())
+			continue
+		}
+		if float64(usage) <= float64(g.baseline)*(1+g.threshold) {
+			continue
+		}
+		pending, err := a.pendingHeads(a.ctx)
+		if err != nil {
+			log.Errorf("Failed checking pending heads Err:%s", err.Error())
+			continue
+		}
+		if len(pending) > 0 {
+			log.Debugf("Automatic GC postponed, %d heads pending", len(pending))
+			continue
+		}
+		log.Infof("Storage grew to %d bytes from %d, running GC", usage, g.baseline)
+		_, err = a.GC(a.ctx)
+		if err != nil {
+			log.Errorf("Automatic GC failed Err:%s", err.Error())
+			continue
+		}
+		g.baseline, err = g.storage.DiskUsage(a.ctx)
+		if err != nil {
+			log.Errorf("Failed reading disk usage Err:%s", err.Error())
+		}
+	}
+}
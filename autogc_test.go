@@ -0,0 +1,81 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	syncds "github.com/ipfs/go-datastore/sync"
+)
+
+type usageStore struct {
+	ds.Batching
+}
+
+func (s *usageStore) DiskUsage(ctx context.Context) (uint64, error) {
+	results, err := s.Query(ctx, query.Query{})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	usage := uint64(0)
+	for r := range results.Next() {
+		if r.Error != nil {
+			return 0, r.Error
+		}
+		usage += uint64(len(r.Key) + len(r.Value))
+	}
+	return usage, nil
+}
+
+func TestAutoGC(t *testing.T) {
+	storage := &usageStore{syncds.MutexWrap(ds.NewMapDatastore())}
+	adb := newLocalAntsDB(t, storage, WithLazyMaterialization(), WithAutoGC(0.2, 50*time.Millisecond))
+	defer adb.Close()
+
+	var val []byte
+	started := time.Now()
+	for adb.Stats().GCRemovedBlocks == 0 {
+		if time.Since(started) > 10*time.Second {
+			t.Fatal("automatic GC did not remove blocks", adb.Stats())
+		}
+		// Every overwrite leaves the previous value unreferenced
+		val = make([]byte, 4096)
+		val[0] = byte(time.Since(started).Milliseconds())
+		err := adb.Put(context.TODO(), "key", val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-time.After(20 * time.Millisecond)
+	}
+	if adb.Stats().GCRuns < 2 {
+		t.Fatal("expected a marking run first", adb.Stats())
+	}
+	got, err := adb.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != val[0] {
+		t.Fatal("current value removed")
+	}
+}
+
+func TestAutoGCWithoutDiskUsage(t *testing.T) {
+	adb := newLocalAntsDB(t, syncds.MutexWrap(ds.NewMapDatastore()),
+		WithLazyMaterialization(), WithAutoGC(0, 10*time.Millisecond))
+	defer adb.Close()
+
+	for i := 0; i < 10; i++ {
+		err := adb.Put(context.TODO(), "key", []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-time.After(100 * time.Millisecond)
+	if runs := adb.Stats().GCRuns; runs != 0 {
+		t.Fatal("unexpected GC runs", runs)
+	}
+}
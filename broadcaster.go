@@ -0,0 +1,213 @@
+package antsdb
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	crdt "github.com/ipfs/go-ds-crdt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	multihash "github.com/multiformats/go-multihash"
+)
+
+type broadcaster interface {
+	crdt.Broadcaster
+	Close()
+}
+
+func (a *AntsDB) newBroadcaster() (broadcaster, error) {
+	if a.pubsub == nil {
+		log.Info("No pubsub provided, running local only")
+		return &localBroadcaster{a: a}, nil
+	}
+	a.topicName = topicID(a.topicName)
+	topic, subs, err := a.joinTopic(a.topicName)
+	if err != nil {
+		return nil, err
+	}
+	b := &pubsubBroadcaster{
+		a:     a,
+		topic: topic,
+		subs:  subs,
+	}
+	if a.eagerConnect {
+		b.stopPeers, err = b.watchPeers(topic)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.maxQueued > 0 {
+		b.queue = newBroadcastQueue(a.maxQueued)
+		a.spawn(b.fillQueue)
+	}
+	if a.bcastRate > 0 {
+		b.limiter = newRateLimiter(a)
+		a.spawn(b.publishQueued)
+	}
+	return b, nil
+}
+
+// topicID is the pubsub topic used for a topic name
+func topicID(name string) string {
+	topicHash, err := multihash.Sum([]byte(name), multihash.MD5, -1)
+	if err != nil {
+		return name
+	}
+	log.Infof("Updating topic name with hash %s", topicHash)
+	return topicHash.B58String()
+}
+
+func (a *AntsDB) joinTopic(id string) (*pubsub.Topic, *pubsub.Subscription, error) {
+	if a.validator != nil {
+		err := a.pubsub.RegisterTopicValidator(
+			id,
+			func(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
+				return a.validator(ctx, p)
+			},
+		)
+		if err != nil {
+			log.Errorf("Failed registering pubsub topic Err:%s", err.Error())
+			return nil, nil, err
+		}
+	}
+	topic, err := a.pubsub.Join(id)
+	if err != nil {
+		log.Errorf("Failed joining pubsub topic Err:%s", err.Error())
+		return nil, nil, err
+	}
+	subs, err := topic.Subscribe()
+	if err != nil {
+		log.Errorf("Failed subscribing to pubsub topic Err:%s", err.Error())
+		return nil, nil, err
+	}
+	return topic, subs, nil
+}
+
+// watchPeers returns a function stopping the watch, which returns once the
+// topic event handler is cancelled so the topic can be closed.
+func (b *pubsubBroadcaster) watchPeers(topic *pubsub.Topic) (func(), error) {
+	handler, err := topic.EventHandler()
+	if err != nil {
+		log.Errorf("Failed watching pubsub topic peers Err:%s", err.Error())
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(b.a.ctx)
+	done := make(chan struct{})
+	watch := b.a.connectTopicPeers(ctx, handler)
+	b.a.spawn(func() {
+		defer close(done)
+		watch()
+	})
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+// pubsubBroadcaster works like the go-ds-crdt PubSubBroadcaster, but keeps
+// hold of the message metadata which the CRDT doesn't see.
+type pubsubBroadcaster struct {
+	a         *AntsDB
+	mtx       sync.Mutex
+	topic     *pubsub.Topic
+	subs      *pubsub.Subscription
+	stopPeers func()
+	// topic being migrated to, see MigrateTopic
+	migrating *pubsub.Topic
+	queue     *broadcastQueue
+	limiter   *rateLimiter
+}
+
+func (b *pubsubBroadcaster) current() (*pubsub.Topic, *pubsub.Subscription) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.topic, b.subs
+}
+
+func (b *pubsubBroadcaster) Broadcast(data []byte) error {
+	b.a.observeHeads(data, b.a.host.ID())
+	if b.limiter != nil && !b.limiter.allow(data) {
+		return nil
+	}
+	return b.publish(data)
+}
+
+func (b *pubsubBroadcaster) publish(data []byte) error {
+	b.mtx.Lock()
+	topic, migrating := b.topic, b.migrating
+	b.mtx.Unlock()
+
+	if migrating != nil {
+		err := migrating.Publish(b.a.ctx, data)
+		if err != nil {
+			log.Errorf("Failed publishing to new topic Err:%s", err.Error())
+		}
+	}
+	return topic.Publish(b.a.ctx, data)
+}
+
+func (b *pubsubBroadcaster) Next() ([]byte, error) {
+	select {
+	case <-b.a.ctx.Done():
+		return nil, crdt.ErrNoMoreBroadcast
+	default:
+	}
+
+	if b.queue != nil {
+		return b.queue.pop(b.a.ctx)
+	}
+	return b.next()
+}
+
+func (b *pubsubBroadcaster) next() ([]byte, error) {
+	_, subs := b.current()
+	msg, err := subs.Next(b.a.ctx)
+	if err != nil {
+		if _, now := b.current(); now != subs && b.a.ctx.Err() == nil {
+			// Migrated to another topic
+			return b.next()
+		}
+		if strings.Contains(err.Error(), "subscription cancelled") ||
+			strings.Contains(err.Error(), "context") {
+			return nil, crdt.ErrNoMoreBroadcast
+		}
+		return nil, err
+	}
+	b.a.observeHeads(msg.GetData(), msg.GetFrom())
+	return msg.GetData(), nil
+}
+
+func (b *pubsubBroadcaster) Close() {
+	b.mtx.Lock()
+	topic, subs, stopPeers := b.topic, b.subs, b.stopPeers
+	b.mtx.Unlock()
+
+	if stopPeers != nil {
+		stopPeers()
+	}
+	subs.Cancel()
+	err := topic.Close()
+	if err != nil {
+		log.Debugf("Failed closing pubsub topic Err:%s", err.Error())
+	}
+}
+
+// localBroadcaster is used when AntsDB runs without pubsub. Nothing is sent
+// and nothing is ever received, so the CRDT only holds local writes.
+type localBroadcaster struct {
+	a *AntsDB
+}
+
+func (b *localBroadcaster) Broadcast(data []byte) error {
+	b.a.observeHeads(data, b.a.host.ID())
+	return nil
+}
+
+func (b *localBroadcaster) Next() ([]byte, error) {
+	<-b.a.ctx.Done()
+	return nil, crdt.ErrNoMoreBroadcast
+}
+
+func (b *localBroadcaster) Close() {}
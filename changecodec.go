@@ -0,0 +1,107 @@
+package antsdb
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var ErrChangeCodecMismatch = errors.New("change log journal written with a different codec")
+
+// ChangeCodec serializes the records of the change log, both in the journal
+// and for sinks implementing ChangeRecordSink.
+type ChangeCodec interface {
+	Marshal(Change) ([]byte, error)
+	Unmarshal([]byte, *Change) error
+}
+
+// ChangeRecordSink is a ChangeSink which takes the changes serialized by
+// the change log codec. Write is not called on sinks implementing it.
+type ChangeRecordSink interface {
+	ChangeSink
+	WriteRecord(seq uint64, record []byte) error
+}
+
+// WithChangeLogCodec serializes change log records with codec instead of
+// JSONChangeCodec. The journal is decoded with the codec it was written
+// with, so New fails with ErrChangeCodecMismatch if changes journaled with
+// another codec are still pending delivery; deliver them before switching.
+func WithChangeLogCodec(codec ChangeCodec) Option {
+	return func(a *AntsDB) {
+		a.changeCodec = codec
+	}
+}
+
+type JSONChangeCodec struct{}
+
+func (JSONChangeCodec) Marshal(c Change) ([]byte, error) { return json.Marshal(c) }
+
+func (JSONChangeCodec) Unmarshal(buf []byte, c *Change) error { return json.Unmarshal(buf, c) }
+
+// ProtoChangeCodec encodes changes as protobuf messages with the fields
+//
+//	uint64 seq = 1;
+//	string key = 2;
+//	bytes value = 3;
+//	bool deleted = 4;
+//	int64 time = 5; // Unix nanoseconds
+//
+// Unknown fields are skipped when decoding.
+type ProtoChangeCodec struct{}
+
+func (ProtoChangeCodec) Marshal(c Change) ([]byte, error) {
+	var buf []byte
+	buf = protowire.AppendTag(buf, 1, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, c.Seq)
+	buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+	buf = protowire.AppendString(buf, c.Key)
+	if len(c.Value) > 0 {
+		buf = protowire.AppendTag(buf, 3, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, c.Value)
+	}
+	if c.Deleted {
+		buf = protowire.AppendTag(buf, 4, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, 1)
+	}
+	buf = protowire.AppendTag(buf, 5, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, uint64(c.Time.UnixNano()))
+	return buf, nil
+}
+
+func (ProtoChangeCodec) Unmarshal(buf []byte, c *Change) error {
+	*c = Change{}
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			c.Seq, n = protowire.ConsumeVarint(buf)
+		case num == 2 && typ == protowire.BytesType:
+			c.Key, n = protowire.ConsumeString(buf)
+		case num == 3 && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(buf)
+			c.Value = append([]byte(nil), v...)
+		case num == 4 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(buf)
+			c.Deleted = v != 0
+		case num == 5 && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(buf)
+			c.Time = time.Unix(0, int64(v))
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
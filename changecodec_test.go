@@ -0,0 +1,115 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p"
+	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
+)
+
+type recordSink struct {
+	testSink
+	records map[uint64][]byte
+}
+
+func (s *recordSink) WriteRecord(seq uint64, record []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("sink unavailable")
+	}
+	s.records[seq] = record
+	c := Change{}
+	err := ProtoChangeCodec{}.Unmarshal(record, &c)
+	if err != nil {
+		return err
+	}
+	s.changes = append(s.changes, c)
+	return nil
+}
+
+func TestProtoChangeCodec(t *testing.T) {
+	for _, c := range []Change{
+		{Seq: 7, Key: "/a", Value: []byte("1"), Time: time.Unix(0, 1234)},
+		{Seq: 8, Key: "/a", Deleted: true, Time: time.Unix(0, 5678)},
+	} {
+		buf, err := ProtoChangeCodec{}.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := Change{}
+		err = ProtoChangeCodec{}.Unmarshal(buf, &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Seq != c.Seq || res.Key != c.Key || string(res.Value) != string(c.Value) ||
+			res.Deleted != c.Deleted || !res.Time.Equal(c.Time) {
+			t.Fatal("incorrect change after decoding", res)
+		}
+	}
+	err := ProtoChangeCodec{}.Unmarshal([]byte{0x0a, 0x05}, &Change{})
+	if err == nil {
+		t.Fatal("expected error on truncated record")
+	}
+}
+
+func TestChangeLogCodecRestart(t *testing.T) {
+	defer func(d time.Duration) { changeRetryDelay = d }(changeRetryDelay)
+	changeRetryDelay = 50 * time.Millisecond
+
+	storage := syncds.MutexWrap(ds.NewMapDatastore())
+
+	// Nothing is delivered before closing
+	sink := &recordSink{testSink: testSink{fail: 1 << 30}, records: map[uint64][]byte{}}
+	adb := newLocalAntsDB(t, storage, WithChangeLogSink(sink), WithChangeLogCodec(ProtoChangeCodec{}))
+	err := adb.Put(context.TODO(), "a", []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.DeleteKey(context.TODO(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adb.Close()
+
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	idht, err := dual.New(context.TODO(), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idht.Close()
+	_, err = New(h, idht, nil, storage, WithChangeLogSink(&testSink{}))
+	if !errors.Is(err, ErrChangeCodecMismatch) {
+		t.Fatal("expected ErrChangeCodecMismatch", err)
+	}
+
+	sink = &recordSink{records: map[uint64][]byte{}}
+	adb = newLocalAntsDB(t, storage, WithChangeLogSink(sink), WithChangeLogCodec(ProtoChangeCodec{}))
+	defer adb.Close()
+
+	started := time.Now()
+	for len(sink.received()) < 2 {
+		if time.Since(started) > 5*time.Second {
+			t.Fatal("changes not delivered", sink.received())
+		}
+		<-time.After(50 * time.Millisecond)
+	}
+	received := sink.received()
+	if received[0].Key != "/a" || string(received[0].Value) != "1" || !received[1].Deleted {
+		t.Fatal("incorrect changes", received)
+	}
+	if len(sink.records) != 2 {
+		t.Fatal("expected records from the codec", len(sink.records))
+	}
+}
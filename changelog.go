@@ -0,0 +1,252 @@
+package antsdb
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var (
+	changeLogNs = "_changelog"
+	// Wait before handing a change to the sink again after it failed
+	changeRetryDelay = time.Second
+)
+
+// Change is a put or delete applied to the local replica, whether written
+// locally or merged from a peer. Records of the change log are serialized
+// with WithChangeLogCodec.
+type Change struct {
+	// Seq increases by one with every change recorded on this node
+	Seq     uint64
+	Key     string
+	Value   []byte `json:",omitempty"`
+	Deleted bool   `json:",omitempty"`
+	Time    time.Time
+}
+
+type ChangeSink interface {
+	Write(change Change) error
+}
+
+// WithChangeLogSink feeds every change to sink in order, leaving out the
+// TTLs and pins AntsDB keeps under ReservedPrefixes. Changes are first
+// journaled in the local datastore along with the sequence number, and
+// removed once the sink accepts them, so they survive restarts and a slow or
+// failing sink does not lose them. A change is only handed over again if the
+// process stops between the sink accepting it and the journal being
+// updated, so sinks needing exactly-once delivery should drop changes with a
+// Seq they already have. A failing Write is retried every second and holds
+// back the changes after it.
+func WithChangeLogSink(sink ChangeSink) Option {
+	return func(a *AntsDB) {
+		a.changes = &changeLog{a: a, sink: sink, notify: make(chan struct{}, 1)}
+	}
+}
+
+// WithChangeLogBackpressure makes the CRDT wait, instead of journaling more
+// changes, while max changes are pending delivery to the sink. Writes on
+// this node and merges from peers stall until the sink catches up. By
+// default pending changes are buffered in the journal without bound.
+func WithChangeLogBackpressure(max int) Option {
+	return func(a *AntsDB) {
+		a.changeBacklog = max
+	}
+}
+
+type changeLog struct {
+	a    *AntsDB
+	sink ChangeSink
+
+	mtx       sync.Mutex
+	next      uint64
+	delivered uint64
+	// delivered changed
+	progress chan struct{}
+	// a change was journaled
+	notify chan struct{}
+}
+
+func (c *changeLog) prefix() ds.Key {
+	return c.a.namespace.ChildString(changeLogNs)
+}
+
+func (c *changeLog) recordKey(seq uint64) ds.Key {
+	return c.prefix().ChildString("r").ChildString(fmt.Sprintf("%016x", seq))
+}
+
+func encodeSeq(seq uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, seq)
+	return buf
+}
+
+func (c *changeLog) loadSeq(ctx context.Context, name string) (uint64, error) {
+	buf, err := c.a.storage.Get(ctx, c.prefix().ChildString(name))
+	if err == ds.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) != 8 {
+		return 0, fmt.Errorf("invalid change log %s sequence", name)
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+func (c *changeLog) load(ctx context.Context) error {
+	next, err := c.loadSeq(ctx, "next")
+	if err != nil {
+		return err
+	}
+	delivered, err := c.loadSeq(ctx, "delivered")
+	if err != nil {
+		return err
+	}
+	c.next, c.delivered = next, delivered
+	c.progress = make(chan struct{})
+
+	// Pending records can only be decoded with the codec they were written
+	// with
+	name := []byte(fmt.Sprintf("%T", c.a.changeCodec))
+	codecKey := c.prefix().ChildString("codec")
+	stored, err := c.a.storage.Get(ctx, codecKey)
+	switch {
+	case err == ds.ErrNotFound:
+	case err != nil:
+		return err
+	case string(stored) == string(name):
+		return nil
+	case next > delivered:
+		return fmt.Errorf("%w: %s, configured %s", ErrChangeCodecMismatch, stored, name)
+	}
+	return c.a.storage.Put(ctx, codecKey, name)
+}
+
+// record journals a change. It runs in the CRDT hooks, which can be called
+// from several goroutines.
+func (c *changeLog) record(key string, val []byte, deleted bool) {
+	if c.a.isMetadata(key) {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for max := uint64(c.a.changeBacklog); max > 0 && c.next-c.delivered >= max; {
+		progress := c.progress
+		c.mtx.Unlock()
+		select {
+		case <-progress:
+		case <-c.a.ctx.Done():
+		}
+		c.mtx.Lock()
+		if c.a.ctx.Err() != nil {
+			return
+		}
+	}
+
+	change := Change{
+		Seq:     c.next,
+		Key:     key,
+		Value:   val,
+		Deleted: deleted,
+		Time:    time.Now(),
+	}
+	buf, err := c.a.changeCodec.Marshal(change)
+	if err != nil {
+		log.Errorf("Failed encoding change %d Err:%s", change.Seq, err.Error())
+		return
+	}
+	err = c.a.storage.Put(c.a.ctx, c.recordKey(change.Seq), buf)
+	if err == nil {
+		err = c.a.storage.Put(c.a.ctx, c.prefix().ChildString("next"), encodeSeq(change.Seq+1))
+	}
+	if err != nil {
+		log.Errorf("Failed journaling change %d for %s Err:%s", change.Seq, key, err.Error())
+		return
+	}
+	c.next++
+	signal(c.notify)
+}
+
+func (c *changeLog) pending() (uint64, uint64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.delivered, c.next
+}
+
+func (c *changeLog) deliver(seq uint64) error {
+	ctx := c.a.ctx
+	buf, err := c.a.storage.Get(ctx, c.recordKey(seq))
+	if err != nil {
+		return err
+	}
+	change := Change{}
+	err = c.a.changeCodec.Unmarshal(buf, &change)
+	if err != nil {
+		return err
+	}
+	if !change.Deleted {
+		// The journal keeps values as stored in the CRDT
+		val, err := c.a.decodeValue(ctx, change.Value)
+		if err != nil {
+			return err
+		}
+		if key, orig, hashed := splitHashedKey(val); hashed {
+			change.Key, val = ds.NewKey(key).String(), orig
+		}
+		change.Value = val
+	}
+	if rs, ok := c.sink.(ChangeRecordSink); ok {
+		var record []byte
+		record, err = c.a.changeCodec.Marshal(change)
+		if err == nil {
+			err = rs.WriteRecord(seq, record)
+		}
+	} else {
+		err = c.sink.Write(change)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	err = c.a.storage.Put(ctx, c.prefix().ChildString("delivered"), encodeSeq(seq+1))
+	if err != nil {
+		return err
+	}
+	c.delivered = seq + 1
+	close(c.progress)
+	c.progress = make(chan struct{})
+	return c.a.storage.Delete(ctx, c.recordKey(seq))
+}
+
+func (c *changeLog) run() {
+	for {
+		delivered, next := c.pending()
+		for seq := delivered; seq < next; seq++ {
+			err := c.deliver(seq)
+			if err != nil {
+				log.Errorf("Failed delivering change %d Err:%s", seq, err.Error())
+				break
+			}
+		}
+		var retry <-chan time.Time
+		if delivered, next = c.pending(); delivered < next {
+			retry = time.After(changeRetryDelay)
+		}
+		select {
+		case <-c.a.ctx.Done():
+			return
+		case <-c.notify:
+		case <-retry:
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSink struct {
+	mtx     sync.Mutex
+	fail    int
+	changes []Change
+}
+
+func (s *testSink) Write(c Change) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("sink unavailable")
+	}
+	s.changes = append(s.changes, c)
+	return nil
+}
+
+func (s *testSink) received() []Change {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return append([]Change{}, s.changes...)
+}
+
+func TestChangeLogSink(t *testing.T) {
+	defer func(d time.Duration) { changeRetryDelay = d }(changeRetryDelay)
+	changeRetryDelay = 50 * time.Millisecond
+
+	sink := &testSink{fail: 2}
+	adb, _ := makeTestingHost(t, WithChangeLogSink(sink), WithLazyMaterialization())
+	defer adb.Close()
+
+	err := adb.Put(context.TODO(), "a", []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.Put(context.TODO(), "b", []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.DeleteKey(context.TODO(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	for len(sink.received()) < 3 {
+		if time.Since(started) > 5*time.Second {
+			t.Fatal("changes not delivered", sink.received())
+		}
+		<-time.After(50 * time.Millisecond)
+	}
+	expected := []Change{
+		{Seq: 0, Key: "/a", Value: []byte("1")},
+		{Seq: 1, Key: "/b", Value: []byte("2")},
+		{Seq: 2, Key: "/a", Deleted: true},
+	}
+	for i, c := range sink.received() {
+		if c.Seq != expected[i].Seq || c.Key != expected[i].Key ||
+			string(c.Value) != string(expected[i].Value) || c.Deleted != expected[i].Deleted {
+			t.Fatal("incorrect change", i, c)
+		}
+	}
+	delivered, next := adb.changes.pending()
+	if delivered != 3 || next != 3 {
+		t.Fatal("incorrect journal state", delivered, next)
+	}
+}
+
+func TestChangeLogSkipsMetadata(t *testing.T) {
+	sink := &testSink{}
+	adb, _ := makeTestingHost(t, WithChangeLogSink(sink))
+	defer adb.Close()
+
+	err := adb.PutWithTTL(context.TODO(), "a", []byte("1"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	for len(sink.received()) < 1 {
+		if time.Since(started) > 5*time.Second {
+			t.Fatal("change not delivered")
+		}
+		<-time.After(50 * time.Millisecond)
+	}
+	// Let any extra change arrive
+	<-time.After(200 * time.Millisecond)
+	if changes := sink.received(); len(changes) != 1 || changes[0].Key != "/a" {
+		t.Fatal("expected a single change for a TTL put", changes)
+	}
+
+	// Put drops the TTL record, which is not reported either
+	err = adb.Put(context.TODO(), "a", []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(200 * time.Millisecond)
+	if changes := sink.received(); len(changes) != 2 || changes[1].Key != "/a" {
+		t.Fatal("expected a single change for a put", changes)
+	}
+}
+
+func TestChangeLogHashedKeys(t *testing.T) {
+	sink := &testSink{}
+	adb, _ := makeTestingHost(t, WithChangeLogSink(sink), WithMaxKeyLength(32), WithKeyHashing())
+	defer adb.Close()
+
+	long := strings.Repeat("k", 64)
+	err := adb.Put(context.TODO(), long, []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	for len(sink.received()) < 1 {
+		if time.Since(started) > 5*time.Second {
+			t.Fatal("change not delivered")
+		}
+		<-time.After(50 * time.Millisecond)
+	}
+	changes := sink.received()
+	if changes[0].Key != "/"+long || string(changes[0].Value) != "val" {
+		t.Fatal("expected the change under the original key", changes[0])
+	}
+}
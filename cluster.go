@@ -0,0 +1,174 @@
+package antsdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// InfoProtocol is the libp2p protocol AntsDB answers ClusterInfo queries
+// on. The querying peer opens a stream and reads a single JSON encoded
+// PeerInfo, written by the other side before it closes the stream.
+const InfoProtocol protocol.ID = "/antsdb/info/1.0.0"
+
+var (
+	infoTimeout = 5 * time.Second
+	// Counting keys walks the whole CRDT, so counts are reused this long
+	infoKeysTTL = 30 * time.Second
+	// Bound on a reply, mostly taken by the heads
+	infoMaxSize = 1 << 20
+)
+
+// PeerInfo is what a node reports about itself to ClusterInfo.
+type PeerInfo struct {
+	Peer      peer.ID
+	Version   string
+	Namespace string
+	// Live keys, counted at most 30 seconds earlier
+	Keys  int
+	Heads []cid.Cid
+}
+
+type keyCount struct {
+	mtx   sync.Mutex
+	count int
+	at    time.Time
+}
+
+func (a *AntsDB) estimateKeys(ctx context.Context) (int, error) {
+	a.keyCount.mtx.Lock()
+	defer a.keyCount.mtx.Unlock()
+
+	if !a.keyCount.at.IsZero() && time.Since(a.keyCount.at) < infoKeysTTL {
+		return a.keyCount.count, nil
+	}
+	results, err := a.crdt.Query(ctx, query.Query{Prefix: "/", KeysOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	count := 0
+	for r := range results.Next() {
+		if r.Error != nil {
+			return 0, r.Error
+		}
+		if !a.isMetadata(r.Key) {
+			count++
+		}
+	}
+	a.keyCount.count, a.keyCount.at = count, time.Now()
+	return count, nil
+}
+
+func (a *AntsDB) localInfo(ctx context.Context) (PeerInfo, error) {
+	info := PeerInfo{
+		Peer:      a.host.ID(),
+		Version:   crdtVersion,
+		Namespace: a.namespace.String(),
+	}
+	var err error
+	info.Keys, err = a.estimateKeys(ctx)
+	if err != nil {
+		return info, err
+	}
+	info.Heads, err = a.currentHeads(ctx)
+	return info, err
+}
+
+func (a *AntsDB) handleInfo(s network.Stream) {
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(a.ctx, infoTimeout)
+	defer cancel()
+
+	info, err := a.localInfo(ctx)
+	if err != nil {
+		log.Errorf("Failed collecting info for %s Err:%s", s.Conn().RemotePeer(), err.Error())
+		_ = s.Reset()
+		return
+	}
+	_ = s.SetWriteDeadline(time.Now().Add(infoTimeout))
+	err = json.NewEncoder(s).Encode(info)
+	if err != nil {
+		log.Debugf("Failed sending info to %s Err:%s", s.Conn().RemotePeer(), err.Error())
+		_ = s.Reset()
+	}
+}
+
+func (a *AntsDB) queryInfo(ctx context.Context, p peer.ID) (PeerInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, infoTimeout)
+	defer cancel()
+
+	s, err := a.host.NewStream(ctx, p, InfoProtocol)
+	if err != nil {
+		return PeerInfo{}, err
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetReadDeadline(deadline)
+	}
+	info := PeerInfo{}
+	err = json.NewDecoder(io.LimitReader(s, int64(infoMaxSize))).Decode(&info)
+	if err != nil {
+		_ = s.Reset()
+		return PeerInfo{}, err
+	}
+	// Only trust the stream for who answered
+	info.Peer = p
+	return info, nil
+}
+
+// ClusterInfo returns what every peer on the topic reports about itself,
+// over InfoProtocol, with this node first and the others sorted by peer ID.
+// Peers which do not answer within 5 seconds, including peers which are not
+// running AntsDB, are left out.
+func (a *AntsDB) ClusterInfo(ctx context.Context) ([]PeerInfo, error) {
+	b, ok := a.bcast.(*pubsubBroadcaster)
+	if !ok {
+		return nil, ErrPubSubRequired
+	}
+	self, err := a.localInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	topic, _ := b.current()
+	var (
+		mtx   sync.Mutex
+		wg    sync.WaitGroup
+		infos []PeerInfo
+	)
+	for _, p := range topic.ListPeers() {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+
+			info, err := a.queryInfo(ctx, p)
+			if err != nil {
+				log.Debugf("Skipping %s in cluster info Err:%s", p, err.Error())
+				return
+			}
+			mtx.Lock()
+			infos = append(infos, info)
+			mtx.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Peer < infos[j].Peer })
+	return append([]PeerInfo{self}, infos...), nil
+}
@@ -0,0 +1,50 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClusterInfo(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t, WithNamespace("other"))
+	defer adb2.Close()
+
+	adb3, h3 := makeTestingHost(t)
+	defer adb3.Close()
+
+	connectHosts(t, h1, h2, h3)
+	<-time.After(time.Second)
+
+	for _, k := range []string{"a", "b"} {
+		err := adb2.Put(context.TODO(), k, []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Stands for a peer not running AntsDB
+	h3.RemoveStreamHandler(InfoProtocol)
+
+	infos, err := adb1.ClusterInfo(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Peer != h1.ID() || infos[1].Peer != h2.ID() {
+		t.Fatal("incorrect peers", infos)
+	}
+	info := infos[1]
+	if info.Version != crdtVersion || info.Namespace != "/other" || info.Keys != 2 || len(info.Heads) != 1 {
+		t.Fatal("incorrect info", info)
+	}
+	heads, err := adb2.currentHeads(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !heads[0].Equals(info.Heads[0]) {
+		t.Fatal("incorrect heads", info.Heads, heads)
+	}
+}
@@ -0,0 +1,146 @@
+package antsdb
+
+import (
+	"context"
+	"encoding/json"
+
+	store "github.com/plexsysio/gkvstore"
+)
+
+// StoreCodec serializes store.Items for the embedded gkvstore layer in
+// place of the items' own Marshal/Unmarshal.
+type StoreCodec interface {
+	Marshal(store.Item) ([]byte, error)
+	Unmarshal([]byte, store.Item) error
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(i store.Item) ([]byte, error) { return json.Marshal(i) }
+
+func (JSONCodec) Unmarshal(buf []byte, i store.Item) error { return json.Unmarshal(buf, i) }
+
+// WithStoreCodec changes how items are serialized into the CRDT. By default
+// items are stored using their own Marshal method. The codec is not recorded
+// with the data, so items written before switching codecs are only readable
+// if the new codec understands their encoding. To migrate, List the items
+// with the old configuration and Update them after switching.
+func WithStoreCodec(codec StoreCodec) Option {
+	return func(a *AntsDB) {
+		a.codec = codec
+	}
+}
+
+type codecStore struct {
+	store.Store
+	codec StoreCodec
+}
+
+type codecItem struct {
+	store.Item
+	codec StoreCodec
+}
+
+func (c *codecItem) Marshal() ([]byte, error) { return c.codec.Marshal(c.Item) }
+
+func (c *codecItem) Unmarshal(buf []byte) error { return c.codec.Unmarshal(buf, c.Item) }
+
+func (c *codecItem) unwrap() store.Item { return c.Item }
+
+// gkvstore checks the items for the optional TimeTracker and IDSetter
+// interfaces, so the wrapper has to expose exactly what the item implements.
+type timedCodecItem struct {
+	*codecItem
+	store.TimeTracker
+}
+
+type idCodecItem struct {
+	*codecItem
+	store.IDSetter
+}
+
+type timedIDCodecItem struct {
+	*codecItem
+	store.TimeTracker
+	store.IDSetter
+}
+
+func (c *codecStore) wrap(i store.Item) store.Item {
+	ci := &codecItem{Item: i, codec: c.codec}
+	tt, timed := i.(store.TimeTracker)
+	ids, hasID := i.(store.IDSetter)
+	switch {
+	case timed && hasID:
+		return &timedIDCodecItem{codecItem: ci, TimeTracker: tt, IDSetter: ids}
+	case timed:
+		return &timedCodecItem{codecItem: ci, TimeTracker: tt}
+	case hasID:
+		return &idCodecItem{codecItem: ci, IDSetter: ids}
+	}
+	return ci
+}
+
+func unwrapItem(i store.Item) store.Item {
+	if w, ok := i.(interface{ unwrap() store.Item }); ok {
+		return w.unwrap()
+	}
+	return i
+}
+
+type codecFilter struct {
+	filter store.ItemFilter
+}
+
+func (f codecFilter) Compare(i store.Item) bool { return f.filter.Compare(unwrapItem(i)) }
+
+func (c *codecStore) Create(ctx context.Context, i store.Item) error {
+	return c.Store.Create(ctx, c.wrap(i))
+}
+
+func (c *codecStore) Read(ctx context.Context, i store.Item) error {
+	return c.Store.Read(ctx, c.wrap(i))
+}
+
+func (c *codecStore) Update(ctx context.Context, i store.Item) error {
+	return c.Store.Update(ctx, c.wrap(i))
+}
+
+func (c *codecStore) Delete(ctx context.Context, i store.Item) error {
+	return c.Store.Delete(ctx, c.wrap(i))
+}
+
+func (c *codecStore) List(
+	ctx context.Context,
+	factory store.Factory,
+	opts store.ListOpt,
+) (<-chan *store.Result, error) {
+	if opts.Filter != nil {
+		opts.Filter = codecFilter{filter: opts.Filter}
+	}
+	results, err := c.Store.List(ctx, func() store.Item { return c.wrap(factory()) }, opts)
+	if err != nil {
+		return nil, err
+	}
+	res := make(chan *store.Result)
+	go func() {
+		defer close(res)
+		// gkvstore cannot stop a listing early, but releases the query once
+		// the rest is read
+		defer func() {
+			for range results {
+			}
+		}()
+
+		for r := range results {
+			if r.Val != nil {
+				r.Val = unwrapItem(r.Val)
+			}
+			select {
+			case res <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return res, nil
+}
@@ -0,0 +1,137 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	store "github.com/plexsysio/gkvstore"
+)
+
+type base64Codec struct{}
+
+func (base64Codec) Marshal(i store.Item) ([]byte, error) {
+	buf, err := json.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf)), nil
+}
+
+func (base64Codec) Unmarshal(buf []byte, i store.Item) error {
+	dec, err := base64.StdEncoding.DecodeString(string(buf))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(dec, i)
+}
+
+func TestStoreCodec(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithStoreCodec(base64Codec{}))
+	defer adb.Close()
+
+	d := &dbObj{
+		Namespace: "antsObj",
+		Id:        "04791e92-0b85-11ea-8d71-362b9e155667",
+		FileName:  "MyTestFile.txt",
+	}
+	err := adb.Create(context.TODO(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.CreatedAt == 0 {
+		t.Fatal("timestamps not set through codec")
+	}
+
+	raw, err := adb.crdt.Get(context.TODO(), ds.NewKey("antsObj/k/"+d.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(raw, []byte("{")) {
+		t.Fatal("item not encoded with codec", string(raw))
+	}
+
+	d2 := &dbObj{Namespace: "antsObj", Id: d.Id}
+	err = adb.Read(context.TODO(), d2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d2.FileName != d.FileName || d2.CreatedAt != d.CreatedAt {
+		t.Fatal("object mismatch after read", d2)
+	}
+
+	list, err := adb.List(context.TODO(), factory("antsObj"), store.ListOpt{
+		Limit: 10,
+		Sort:  store.SortCreatedAsc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for it := range list {
+		if it.Err != nil {
+			t.Fatal(it.Err)
+		}
+		if it.Val.(*dbObj).FileName != d.FileName {
+			t.Fatal("object mismatch in list")
+		}
+		count++
+	}
+	if count != 1 {
+		t.Fatal("incorrect list count", count)
+	}
+
+	err = adb.Delete(context.TODO(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.Read(context.TODO(), d2)
+	if err == nil {
+		t.Fatal("able to read object after delete")
+	}
+}
+
+func TestStoreCodecListCancel(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithStoreCodec(base64Codec{}))
+	defer adb.Close()
+
+	items := 50
+	for i := 0; i < items; i++ {
+		err := adb.Create(context.TODO(), &dbObj{Namespace: "antsObj", Id: fmt.Sprintf("obj%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	list, err := adb.List(ctx, factory("antsObj"), store.ListOpt{Limit: int64(items)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-list
+	cancel()
+
+	// The listing stops instead of waiting for a reader
+	count := 1
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case _, ok := <-list:
+			if !ok {
+				done = true
+				continue
+			}
+			count++
+		case <-timeout:
+			t.Fatal("list not closed after cancel")
+		}
+	}
+	if count == items {
+		t.Fatal("listing not stopped by cancel")
+	}
+}
@@ -0,0 +1,129 @@
+package antsdb
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	ds "github.com/ipfs/go-datastore"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	metrics "github.com/ipfs/go-metrics-interface"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+var conflictCacheSize = 4096
+
+// conflicts estimates how often concurrent writes to the same key are
+// resolved by the LWW rules. go-ds-crdt does not expose which deltas it
+// merges, so deltas are inspected as the CRDT fetches them from the DAG
+// syncer, which only happens for deltas created by other peers.
+//
+// Priorities grow along every path of the DAG, so an incoming delta whose
+// priority is not higher than the one of the current value cannot descend
+// from the write which set it: both were made without knowing about each
+// other. The exception is a branch being walked from its head down, where
+// older deltas of the branch are fetched after newer ones have been applied;
+// deltas fetched in the same walk as the current value are not counted.
+//
+// This is a lower bound. An incoming concurrent delta with a higher priority
+// overwrites the value without being detected, as telling it apart from a
+// descendant would need a walk of the history on every delta.
+type conflicts struct {
+	// Updated atomically, see AntsDB
+	total   uint64
+	walks   uint64
+	counter metrics.Counter
+	mtx     sync.Mutex
+	// deltas already inspected, as the CRDT may fetch a delta more than once
+	seen *lru.Cache
+	// walk which last set each key
+	lastWalk *lru.Cache
+}
+
+func newConflicts(ctx context.Context) *conflicts {
+	seen, _ := lru.New(conflictCacheSize)
+	lastWalk, _ := lru.New(conflictCacheSize)
+	return &conflicts{
+		counter: metrics.NewCtx(
+			metrics.CtxSubScope(ctx, "antsdb"),
+			"conflicts_resolved_total",
+			"Concurrent writes to a key resolved by priority",
+		).Counter(),
+		seen:     seen,
+		lastWalk: lastWalk,
+	}
+}
+
+// walk identifies one session of the CRDT fetching a branch.
+type walk struct {
+	id uint64
+	// peer credited with the deltas of the branch, see WithWriterTracking
+	writer peer.ID
+}
+
+func (c *conflicts) newWalk() *walk {
+	return &walk{id: atomic.AddUint64(&c.walks, 1)}
+}
+
+func (a *AntsDB) currentPriority(ctx context.Context, key string) (uint64, bool, error) {
+	prioKey := a.namespace.ChildString("s").ChildString("k").ChildString(key).ChildString("p")
+	buf, err := a.storage.Get(ctx, prioKey)
+	if err == ds.ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	prio, n := binary.Uvarint(buf)
+	if n <= 0 || prio == 0 {
+		return 0, false, nil
+	}
+	return prio - 1, true, nil
+}
+
+func (a *AntsDB) inspectDelta(ctx context.Context, w *walk, nd ipld.Node) {
+	protoNode, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return
+	}
+	c := a.conflicts
+	if found, _ := c.seen.ContainsOrAdd(nd.Cid(), struct{}{}); found {
+		return
+	}
+	delta := &pb.Delta{}
+	if proto.Unmarshal(protoNode.Data(), delta) != nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, e := range delta.GetElements() {
+		cur, found, err := a.currentPriority(ctx, e.GetKey())
+		if err != nil {
+			log.Debugf("Failed reading priority of %s Err:%s", e.GetKey(), err.Error())
+			continue
+		}
+		last, _ := c.lastWalk.Get(e.GetKey())
+		if found && delta.GetPriority() <= cur && last != w.id {
+			atomic.AddUint64(&c.total, 1)
+			c.counter.Inc()
+			log.Debugf("Concurrent write to %s in %s", e.GetKey(), nd.Cid())
+		}
+		if !found || delta.GetPriority() >= cur {
+			c.lastWalk.Add(e.GetKey(), w.id)
+		}
+	}
+}
+
+// local writes start a new lineage for the keys
+func (c *conflicts) wrote(keys []ds.Key) {
+	for _, k := range keys {
+		c.lastWalk.Remove(k.String())
+	}
+}
@@ -0,0 +1,94 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConflictsResolved(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	// Both writes are made without knowing about each other
+	err := adb1.Put(context.TODO(), "shared", []byte("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb2.Put(context.TODO(), "shared", []byte("two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connectHosts(t, h1, h2)
+	<-time.After(time.Second * 3)
+
+	val1, err := adb1.Get(context.TODO(), "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	val2, err := adb2.Get(context.TODO(), "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val1) != string(val2) {
+		t.Fatal("replicas did not converge")
+	}
+	if adb1.Stats().ConflictsResolved != 1 || adb2.Stats().ConflictsResolved != 1 {
+		t.Fatal("expected conflict on both replicas", adb1.Stats(), adb2.Stats())
+	}
+
+	// Sequential writes are not conflicts
+	for i := 0; i < 3; i++ {
+		err = adb1.Put(context.TODO(), "shared", []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-time.After(time.Second)
+		err = adb2.Put(context.TODO(), "shared", []byte{byte(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-time.After(time.Second)
+	}
+	if adb1.Stats().ConflictsResolved != 1 || adb2.Stats().ConflictsResolved != 1 {
+		t.Fatal("sequential writes counted as conflicts", adb1.Stats(), adb2.Stats())
+	}
+}
+
+func TestConflictsResolvedRepeatedly(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	adb3, h3 := makeTestingHost(t)
+	defer adb3.Close()
+
+	// Every round adb2 and adb3 write the key while partitioned, and adb1
+	// merges both writes in separate walks
+	for round := 1; round <= 2; round++ {
+		err := adb2.Put(context.TODO(), "shared", []byte{2, byte(round)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = adb3.Put(context.TODO(), "shared", []byte{3, byte(round)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		connectHosts(t, h1, h2)
+		<-time.After(time.Second * 3)
+		connectHosts(t, h1, h3)
+		<-time.After(time.Second * 3)
+
+		if c := adb1.Stats().ConflictsResolved; c != uint64(round) {
+			t.Fatal("expected a conflict every round", round, c)
+		}
+		h1.Network().ClosePeer(h2.ID())
+		h1.Network().ClosePeer(h3.ID())
+		<-time.After(time.Second)
+	}
+}
@@ -0,0 +1,164 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	dag "github.com/ipfs/go-merkledag"
+	"google.golang.org/protobuf/proto"
+)
+
+// go-ds-crdt keeps the current heads under this namespace
+var headsNs = "h"
+
+// blockID is the identifier go-ds-crdt uses for the elements and tombstones
+// added by a block
+func blockID(c cid.Cid) string {
+	return dshelp.MultihashToDsKey(c.Hash()).String()
+}
+
+func (a *AntsDB) getDelta(ctx context.Context, c cid.Cid) (*dag.ProtoNode, *pb.Delta, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.dagTimeout)
+	defer cancel()
+
+	nd, err := a.syncer.Get(ctx, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	protoNode, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nil, nil, errors.New("node is not a CRDT delta")
+	}
+	delta := &pb.Delta{}
+	err = proto.Unmarshal(protoNode.Data(), delta)
+	if err != nil {
+		return nil, nil, err
+	}
+	return protoNode, delta, nil
+}
+
+func (a *AntsDB) currentHeads(ctx context.Context) ([]cid.Cid, error) {
+	prefix := a.namespace.ChildString(headsNs).String()
+	results, err := a.storage.Query(ctx, query.Query{Prefix: prefix, KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	heads := []cid.Cid{}
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		mh, err := dshelp.DsKeyToMultihash(ds.NewKey(strings.TrimPrefix(r.Key, prefix)))
+		if err != nil {
+			return nil, err
+		}
+		heads = append(heads, cid.NewCidV1(cid.DagProtobuf, mh))
+	}
+	return heads, nil
+}
+
+// errSkipLinks makes walkDAG skip the deltas linked from the one visited,
+// unless they are reachable some other way.
+var errSkipLinks = errors.New("skip links")
+
+// walkDAG calls visit once for every delta reachable from heads. Blocks
+// which are not available locally are fetched from peers.
+func (a *AntsDB) walkDAG(
+	ctx context.Context,
+	heads []cid.Cid,
+	visit func(cid.Cid, *pb.Delta) error,
+) error {
+	seen := cid.NewSet()
+	queue := append([]cid.Cid(nil), heads...)
+	for len(queue) > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c := queue[0]
+		queue = queue[1:]
+		if !seen.Visit(c) {
+			continue
+		}
+		nd, delta, err := a.getDelta(ctx, c)
+		if err != nil {
+			return err
+		}
+		err = visit(c, delta)
+		if err == errSkipLinks {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, l := range nd.Links() {
+			queue = append(queue, l.Cid)
+		}
+	}
+	return nil
+}
+
+// GetAtHead resolves the value key had when head was the latest delta, by
+// replaying the CRDT rules over every delta reachable from head. This walks
+// the whole DAG under head, fetching missing blocks from peers, so its cost
+// grows with the history; it can only answer for heads whose blocks are
+// still retained somewhere in the network. Expiry of TTL keys is not taken
+// into account.
+func (a *AntsDB) GetAtHead(ctx context.Context, key string, head cid.Cid) ([]byte, error) {
+	stored, hashed, err := a.storedKey(key)
+	if err != nil {
+		return nil, err
+	}
+	dsKey := ds.NewKey(stored).String()
+
+	type element struct {
+		priority uint64
+		value    []byte
+	}
+	elems := make(map[string]element)
+	tombs := make(map[string]struct{})
+
+	err = a.walkDAG(ctx, []cid.Cid{head}, func(c cid.Cid, delta *pb.Delta) error {
+		for _, e := range delta.GetElements() {
+			if e.GetKey() == dsKey {
+				elems[blockID(c)] = element{priority: delta.GetPriority(), value: e.GetValue()}
+			}
+		}
+		for _, t := range delta.GetTombstones() {
+			if t.GetKey() == dsKey {
+				tombs[t.GetId()] = struct{}{}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		found bool
+		best  element
+	)
+	for id, e := range elems {
+		if _, deleted := tombs[id]; deleted {
+			continue
+		}
+		if !found || e.priority > best.priority ||
+			(e.priority == best.priority && bytes.Compare(e.value, best.value) > 0) {
+			best = e
+			found = true
+		}
+	}
+	if !found {
+		return nil, ds.ErrNotFound
+	}
+	return a.decodeEntry(ctx, key, hashed, best.value)
+}
@@ -0,0 +1,68 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+)
+
+func singleHead(t *testing.T, adb *AntsDB) cid.Cid {
+	heads, err := adb.currentHeads(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heads) != 1 {
+		t.Fatal("expected single head", heads)
+	}
+	return heads[0]
+}
+
+func TestGetAtHead(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	err := adb.Put(context.TODO(), "key", []byte("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head1 := singleHead(t, adb)
+
+	err = adb.Put(context.TODO(), "key", []byte("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head2 := singleHead(t, adb)
+
+	err = adb.DeleteKey(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head3 := singleHead(t, adb)
+
+	for _, tc := range []struct {
+		head cid.Cid
+		val  string
+	}{
+		{head: head1, val: "v1"},
+		{head: head2, val: "v2"},
+	} {
+		val, err := adb.GetAtHead(context.TODO(), "key", tc.head)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != tc.val {
+			t.Fatal("incorrect value at head", tc.head, string(val))
+		}
+	}
+
+	_, err = adb.GetAtHead(context.TODO(), "key", head3)
+	if err != ds.ErrNotFound {
+		t.Fatal("expected key to be deleted at head", err)
+	}
+	_, err = adb.GetAtHead(context.TODO(), "other", head2)
+	if err != ds.ErrNotFound {
+		t.Fatal("expected missing key", err)
+	}
+}
@@ -0,0 +1,235 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	cid "github.com/ipfs/go-cid"
+	crdt "github.com/ipfs/go-ds-crdt"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/protobuf/proto"
+)
+
+var applyPollInterval = 10 * time.Millisecond
+
+// AppliedDelta is a CRDT delta as it was handed to the CRDT, either created
+// by a local write or fetched while merging the DAG of a peer.
+type AppliedDelta struct {
+	Cid   cid.Cid
+	Block []byte
+	Local bool
+}
+
+// WithDeltaHook calls hook with every delta in the order the CRDT receives
+// them. The CRDT applies deltas from several workers, so deltas fetched
+// together may be applied in a slightly different order than reported.
+func WithDeltaHook(hook func(AppliedDelta)) Option {
+	return func(a *AntsDB) {
+		a.deltaHook = hook
+		a.hookedDeltas, _ = lru.New(conflictCacheSize)
+	}
+}
+
+func (a *AntsDB) observeDelta(ctx context.Context, w *walk, nd ipld.Node) {
+	if w != nil {
+		a.inspectDelta(ctx, w, nd)
+	}
+	if a.lastWriters != nil {
+		a.attributeDelta(w, nd)
+	}
+	a.stashDelta(nd, w == nil)
+	if a.deltaHook == nil {
+		return
+	}
+	if _, isDelta := nd.(*dag.ProtoNode); !isDelta {
+		return
+	}
+	// The CRDT may fetch the same delta more than once
+	if found, _ := a.hookedDeltas.ContainsOrAdd(nd.Cid(), struct{}{}); found {
+		return
+	}
+	a.deltaHook(AppliedDelta{Cid: nd.Cid(), Block: nd.RawData(), Local: w == nil})
+}
+
+// crdtDAG is the DAG syncer as seen by the CRDT
+type crdtDAG struct {
+	crdt.SessionDAGService
+	a *AntsDB
+}
+
+func (d *crdtDAG) Add(ctx context.Context, nd ipld.Node) error {
+	err := d.SessionDAGService.Add(ctx, nd)
+	if err != nil {
+		return err
+	}
+	d.a.collected(nd.Cid())
+	d.a.observeDelta(ctx, nil, nd)
+	return nil
+}
+
+func (d *crdtDAG) Session(ctx context.Context) ipld.NodeGetter {
+	w := d.a.conflicts.newWalk()
+	d.a.branches.open(ctx, w)
+	return &crdtSession{
+		NodeGetter: d.SessionDAGService.Session(ctx),
+		a:          d.a,
+		walk:       w,
+	}
+}
+
+type crdtSession struct {
+	ipld.NodeGetter
+	a    *AntsDB
+	walk *walk
+}
+
+func (s *crdtSession) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	attrs := []attribute.KeyValue{attribute.String("cid", c.String())}
+	if from, found := s.a.writers.seen.Peek(c); found {
+		attrs = append(attrs, attribute.String("peer", from.(peer.ID).String()))
+	}
+	ctx, span := s.a.startSpan(ctx, "FetchDelta", attrs...)
+	nd, err := s.NodeGetter.Get(ctx, c)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("size", len(nd.RawData())))
+	endSpan(span, nil)
+	s.a.observeDelta(ctx, s.walk, nd)
+	return nd, nil
+}
+
+func (s *crdtSession) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	ctx, span := s.a.startSpan(ctx, "FetchDeltas", attribute.Int("count", len(cids)))
+	res := make(chan *ipld.NodeOption, len(cids))
+	nodes := s.NodeGetter.GetMany(ctx, cids)
+	go func() {
+		defer close(res)
+
+		var err error
+		for opt := range nodes {
+			if opt.Err == nil {
+				s.a.observeDelta(ctx, s.walk, opt.Node)
+			} else {
+				err = opt.Err
+			}
+			res <- opt
+		}
+		endSpan(span, err)
+	}()
+	return res
+}
+
+type nextBroadcast struct {
+	data []byte
+	err  error
+}
+
+// injector lets AntsDB hand broadcasts of its own to the CRDT, next to the
+// ones received from peers.
+type injector struct {
+	broadcaster
+	a        *AntsDB
+	incoming chan nextBroadcast
+	injected chan []byte
+}
+
+func newInjector(a *AntsDB, b broadcaster) *injector {
+	i := &injector{
+		broadcaster: b,
+		a:           a,
+		incoming:    make(chan nextBroadcast),
+		injected:    make(chan []byte),
+	}
+	a.spawn(i.receive)
+	return i
+}
+
+func (i *injector) receive() {
+	for {
+		data, err := i.broadcaster.Next()
+		select {
+		case i.incoming <- nextBroadcast{data: data, err: err}:
+		case <-i.a.ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (i *injector) Next() ([]byte, error) {
+	select {
+	case <-i.a.ctx.Done():
+		return nil, crdt.ErrNoMoreBroadcast
+	case n := <-i.incoming:
+		return n.data, n.err
+	case data := <-i.injected:
+		return data, nil
+	}
+}
+
+// ApplyDeltas adds the delta blocks to the DAG and has the CRDT merge them
+// one at a time in the given order, as if each had just been announced by a
+// peer. A delta whose links are not applied yet makes the CRDT walk down
+// and apply those first, so for an exact replay the deltas should come in
+// the order they were originally applied, like AppliedDeltas recorded with
+// WithDeltaHook.
+func (a *AntsDB) ApplyDeltas(ctx context.Context, deltas []AppliedDelta) error {
+	for _, d := range deltas {
+		nd, err := dag.DecodeProtobuf(d.Block)
+		if err != nil {
+			return err
+		}
+		nd.SetCidBuilder(d.Cid.Prefix())
+		if !nd.Cid().Equals(d.Cid) {
+			return errors.New("delta block does not match its CID")
+		}
+		err = a.syncer.Add(ctx, nd)
+		if err != nil {
+			return err
+		}
+	}
+	for _, d := range deltas {
+		err := a.applyDelta(ctx, d.Cid)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AntsDB) applyDelta(ctx context.Context, c cid.Cid) error {
+	data, err := proto.Marshal(&pb.CRDTBroadcast{Heads: []*pb.Head{{Cid: c.Bytes()}}})
+	if err != nil {
+		return err
+	}
+	select {
+	case a.injector.injected <- data:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.ctx.Done():
+		return a.ctx.Err()
+	}
+	for {
+		done, err := a.isProcessed(ctx, c)
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.ctx.Done():
+			return a.ctx.Err()
+		case <-time.After(applyPollInterval):
+		}
+	}
+}
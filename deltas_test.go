@@ -0,0 +1,49 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeltaHook(t *testing.T) {
+	var (
+		mtx    sync.Mutex
+		local  int
+		remote int
+	)
+	hook := WithDeltaHook(func(d AppliedDelta) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if d.Local {
+			local++
+		} else {
+			remote++
+		}
+	})
+
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t, hook)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+
+	err := adb1.Put(context.TODO(), "remote", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb2.Put(context.TODO(), "local", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(3 * time.Second)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if local != 1 || remote != 1 {
+		t.Fatal("incorrect deltas observed", local, remote)
+	}
+}
@@ -0,0 +1,115 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/routing"
+)
+
+var (
+	dhtProbeInterval = 10 * time.Second
+	dhtProbeTimeout  = 5 * time.Second
+)
+
+// WithDHTOptional keeps AntsDB running in a pubsub-only mode while the DHT
+// is unreachable, instead of stalling block exchange on lookups that cannot
+// succeed. The DHT is probed periodically and used again as soon as it
+// answers; every transition is logged.
+//
+// While degraded, provider records are neither published nor searched for.
+// Deltas still propagate over pubsub, but their blocks can only be fetched
+// from peers this node is already connected to, so a replica whose only
+// holder of a block is an unconnected peer will stall until the DHT is back.
+func WithDHTOptional() Option {
+	return func(a *AntsDB) {
+		a.dhtOptional = true
+	}
+}
+
+// optionalRouting is handed to ipfs-lite in place of the DHT when
+// WithDHTOptional is set.
+type optionalRouting struct {
+	routing.Routing
+	self peer.ID
+	down int32
+}
+
+func (r *optionalRouting) degraded() bool {
+	return atomic.LoadInt32(&r.down) == 1
+}
+
+func (r *optionalRouting) setDegraded(down bool, reason error) {
+	if down {
+		if atomic.CompareAndSwapInt32(&r.down, 0, 1) {
+			log.Warnf("DHT unavailable, switching to pubsub-only sync Err:%v", reason)
+		}
+		return
+	}
+	if atomic.CompareAndSwapInt32(&r.down, 1, 0) {
+		log.Info("DHT available again, resuming provider lookups")
+	}
+}
+
+func (r *optionalRouting) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	if r.degraded() {
+		return nil
+	}
+	err := r.Routing.Provide(ctx, c, announce)
+	if err != nil && ctx.Err() == nil {
+		r.setDegraded(true, err)
+	}
+	return err
+}
+
+func (r *optionalRouting) FindProvidersAsync(
+	ctx context.Context,
+	c cid.Cid,
+	count int,
+) <-chan peer.AddrInfo {
+	if r.degraded() {
+		res := make(chan peer.AddrInfo)
+		close(res)
+		return res
+	}
+	return r.Routing.FindProvidersAsync(ctx, c, count)
+}
+
+// probe looks up our own ID. Not finding it still means DHT peers answered.
+func (r *optionalRouting) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, dhtProbeTimeout)
+	defer cancel()
+
+	_, err := r.Routing.FindPeer(ctx, r.self)
+	if err != nil && !errors.Is(err, routing.ErrNotFound) {
+		r.setDegraded(true, err)
+		return
+	}
+	r.setDegraded(false, nil)
+}
+
+func (a *AntsDB) probeDHT() {
+	a.dhtProbe.probe(a.ctx)
+
+	ticker := time.NewTicker(dhtProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.dhtProbe.probe(a.ctx)
+		}
+	}
+}
+
+// DHTAvailable reports whether the DHT is currently used for block discovery.
+// Without WithDHTOptional it is always true.
+func (a *AntsDB) DHTAvailable() bool {
+	return a.dhtProbe == nil || !a.dhtProbe.degraded()
+}
@@ -0,0 +1,63 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDHTOptional(t *testing.T) {
+	interval := dhtProbeInterval
+	dhtProbeInterval = 200 * time.Millisecond
+	defer func() { dhtProbeInterval = interval }()
+
+	adb1, h1 := makeTestingHost(t, WithDHTOptional())
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	waitFor := func(available bool) {
+		t.Helper()
+		deadline := time.After(10 * time.Second)
+		for adb1.DHTAvailable() != available {
+			select {
+			case <-deadline:
+				t.Fatalf("expected DHT available %t", available)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+
+	// No DHT peers yet
+	waitFor(false)
+
+	connectHosts(t, h1, h2)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	err := adb2.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for {
+		val, err := adb1.Get(ctx, "key")
+		if err == nil {
+			if !bytes.Equal(val, []byte("val")) {
+				t.Fatal("incorrect value", string(val))
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("value not synced", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	waitFor(true)
+}
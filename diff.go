@@ -0,0 +1,125 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var diffSamples = 10
+
+// DiffReport counts the keys which differ between two namespaces. Keys are
+// compared relative to their namespace and the samples hold the first few
+// keys found of each kind.
+type DiffReport struct {
+	OnlyInA int
+	OnlyInB int
+	Changed int
+
+	SampleOnlyInA []string
+	SampleOnlyInB []string
+	SampleChanged []string
+}
+
+func (d DiffReport) Equal() bool {
+	return d.OnlyInA == 0 && d.OnlyInB == 0 && d.Changed == 0
+}
+
+func addSample(samples []string, key string) []string {
+	if len(samples) < diffSamples {
+		return append(samples, key)
+	}
+	return samples
+}
+
+// Diff compares all the keys under nsA with the ones under nsB, for example
+// to verify a migration. The underlying datastore does not guarantee ordered
+// iteration, so instead of merging two sorted listings each namespace is
+// streamed once and every key is looked up in the other one. Memory use stays
+// constant regardless of the size of the namespaces. Values are compared once
+// decrypted and materialized.
+func (a *AntsDB) Diff(ctx context.Context, nsA, nsB string) (DiffReport, error) {
+	report := DiffReport{}
+	if a.packer != nil {
+		err := a.flushPacked(ctx)
+		if err != nil {
+			return report, err
+		}
+	}
+	prefixA, prefixB := ds.NewKey(nsA), ds.NewKey(nsB)
+
+	err := a.scanDiff(ctx, prefixA, prefixB, func(key string, found, equal bool) {
+		switch {
+		case !found:
+			report.OnlyInA++
+			report.SampleOnlyInA = addSample(report.SampleOnlyInA, key)
+		case !equal:
+			report.Changed++
+			report.SampleChanged = addSample(report.SampleChanged, key)
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+	// Keys in both were already compared
+	err = a.scanDiff(ctx, prefixB, prefixA, func(key string, found, _ bool) {
+		if !found {
+			report.OnlyInB++
+			report.SampleOnlyInB = addSample(report.SampleOnlyInB, key)
+		}
+	})
+	return report, err
+}
+
+func (a *AntsDB) scanDiff(
+	ctx context.Context,
+	from, to ds.Key,
+	visit func(key string, found, equal bool),
+) error {
+	results, err := a.crdt.Query(ctx, query.Query{Prefix: from.String()})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		rel := strings.TrimPrefix(r.Key, from.String())
+		other, err := a.crdt.Get(ctx, to.Child(ds.NewKey(rel)))
+		switch {
+		case err == ds.ErrNotFound:
+			visit(rel, false, false)
+		case err != nil:
+			return err
+		default:
+			equal, err := a.sameValue(ctx, r.Value, other)
+			if err != nil {
+				return err
+			}
+			visit(rel, true, equal)
+		}
+	}
+	return nil
+}
+
+// sameValue compares stored values by what they decode to, as sealing and
+// lazy materialization store equal values differently.
+func (a *AntsDB) sameValue(ctx context.Context, x, y []byte) (bool, error) {
+	if bytes.Equal(x, y) {
+		return true, nil
+	}
+	x, err := a.decodeValue(ctx, x)
+	if err != nil {
+		return false, err
+	}
+	y, err = a.decodeValue(ctx, y)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(x, y), nil
+}
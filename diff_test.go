@@ -0,0 +1,65 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Plain", nil},
+		{"Encrypted", []Option{WithValueEncryption(bytes.Repeat([]byte{1}, 32))}},
+		{"Lazy", []Option{WithLazyMaterialization()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			adb, _ := makeTestingHost(t, tc.opts...)
+			defer adb.Close()
+
+			for k, v := range map[string]string{
+				"old/same":     "1",
+				"old/changed":  "1",
+				"old/removed":  "1",
+				"new/same":     "1",
+				"new/changed":  "2",
+				"new/added":    "1",
+				"copy/same":    "1",
+				"copy/changed": "1",
+				"copy/removed": "1",
+			} {
+				err := adb.Put(context.TODO(), k, []byte(v))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := adb.Diff(context.TODO(), "old", "new")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Equal() {
+				t.Fatal("expected differences")
+			}
+			if report.OnlyInA != 1 || report.SampleOnlyInA[0] != "/removed" {
+				t.Fatal("incorrect keys only in A", report)
+			}
+			if report.OnlyInB != 1 || report.SampleOnlyInB[0] != "/added" {
+				t.Fatal("incorrect keys only in B", report)
+			}
+			if report.Changed != 1 || report.SampleChanged[0] != "/changed" {
+				t.Fatal("incorrect changed keys", report)
+			}
+
+			report, err = adb.Diff(context.TODO(), "old", "copy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Equal() {
+				t.Fatal("expected no differences", report)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package antsdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+)
+
+var (
+	topicPeerTag     = "antsdb-topic"
+	topicPeerWeight  = 20
+	eagerDialTimeout = 10 * time.Second
+)
+
+// WithEagerPeerConnect dials peers as soon as they join the CRDT topic and
+// tags them in the connection manager, so their connections are the last to
+// be trimmed and DAG blocks can be fetched from them directly. No dial is
+// attempted once the connection manager is at its high water mark.
+//
+// Every replica ends up connected to every other one it hears about, which
+// in large topics means many more connections than gossipsub alone keeps.
+// It has no effect without pubsub.
+func WithEagerPeerConnect() Option {
+	return func(a *AntsDB) {
+		a.eagerConnect = true
+	}
+}
+
+func (a *AntsDB) belowConnLimit() bool {
+	cm, ok := a.host.ConnManager().(interface{ GetInfo() connmgr.CMInfo })
+	if !ok {
+		return true
+	}
+	info := cm.GetInfo()
+	return info.HighWater == 0 || info.ConnCount < info.HighWater
+}
+
+func (a *AntsDB) connectTopicPeers(ctx context.Context, handler *pubsub.TopicEventHandler) func() {
+	return func() {
+		defer handler.Cancel()
+
+		for {
+			ev, err := handler.NextPeerEvent(ctx)
+			if err != nil {
+				return
+			}
+			switch ev.Type {
+			case pubsub.PeerJoin:
+				a.host.ConnManager().TagPeer(ev.Peer, topicPeerTag, topicPeerWeight)
+				if a.host.Network().Connectedness(ev.Peer) == network.Connected {
+					continue
+				}
+				if !a.belowConnLimit() {
+					log.Debugf("Not dialing topic peer %s, connection limit reached", ev.Peer)
+					continue
+				}
+				p := ev.Peer
+				a.spawn(func() {
+					ctx, cancel := context.WithTimeout(a.ctx, eagerDialTimeout)
+					defer cancel()
+
+					err := a.host.Connect(ctx, peer.AddrInfo{ID: p})
+					if err != nil {
+						log.Debugf("Failed dialing topic peer %s Err:%s", p, err.Error())
+					}
+				})
+			case pubsub.PeerLeave:
+				a.host.ConnManager().UntagPeer(ev.Peer, topicPeerTag)
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEagerPeerConnect(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithEagerPeerConnect())
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for {
+		info := h1.ConnManager().GetTagInfo(h2.ID())
+		if info != nil && info.Tags[topicPeerTag] == topicPeerWeight {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("topic peer not tagged")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if info := h2.ConnManager().GetTagInfo(h1.ID()); info != nil && info.Tags[topicPeerTag] != 0 {
+		t.Fatal("peer tagged without eager connect")
+	}
+}
@@ -0,0 +1,361 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var (
+	// Encrypted values are stored as this marker, the ID of the key, the
+	// nonce and the sealed value.
+	encPrefix = []byte("\x00antsdb/enc\x00")
+	encIDLen  = 8
+
+	rotationNs           = "_rotation"
+	rotationSaveInterval = 100
+
+	ErrEncryptionDisabled   = errors.New("value encryption is not enabled")
+	ErrRotationInProgress   = errors.New("encryption key rotation already in progress")
+	ErrUnknownEncryptionKey = errors.New("value encrypted with an unknown key")
+)
+
+// WithValueEncryption encrypts the values written with Put and PutWithTTL
+// using AES-GCM, so they are replicated and stored as ciphertext. Items of
+// the embedded store are not encrypted. Values sealed with any of the old
+// keys remain readable, which is needed to resume an interrupted
+// RotateEncryptionKey. The key must be 16, 24 or 32 bytes long.
+func WithValueEncryption(key []byte, old ...[]byte) Option {
+	return func(a *AntsDB) {
+		a.encKeys = append([][]byte{key}, old...)
+	}
+}
+
+type keyring struct {
+	mtx     sync.RWMutex
+	current string
+	aeads   map[string]cipher.AEAD
+	// a rotation is running
+	rotating bool
+}
+
+func encKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return string(sum[:encIDLen])
+}
+
+func newKeyring(keys [][]byte) (*keyring, error) {
+	k := &keyring{aeads: make(map[string]cipher.AEAD)}
+	for i := len(keys) - 1; i >= 0; i-- {
+		id, _, err := k.add(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		k.use(id)
+	}
+	return k, nil
+}
+
+// add registers key for opening values, it reports whether the key was new
+func (k *keyring) add(key []byte) (string, bool, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", false, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", false, err
+	}
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	id := encKeyID(key)
+	_, found := k.aeads[id]
+	k.aeads[id] = aead
+	return id, !found, nil
+}
+
+// use makes the key with id the one used for sealing new values
+func (k *keyring) use(id string) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	k.current = id
+}
+
+func (k *keyring) remove(id string) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if id != k.current {
+		delete(k.aeads, id)
+	}
+}
+
+func (k *keyring) startRotation() bool {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	if k.rotating {
+		return false
+	}
+	k.rotating = true
+	return true
+}
+
+func (k *keyring) endRotation() {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	k.rotating = false
+}
+
+func (k *keyring) dropOld() {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	for id := range k.aeads {
+		if id != k.current {
+			delete(k.aeads, id)
+		}
+	}
+}
+
+func (k *keyring) seal(val []byte) ([]byte, error) {
+	k.mtx.RLock()
+	id, aead := k.current, k.aeads[k.current]
+	k.mtx.RUnlock()
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	buf := append(append([]byte(nil), encPrefix...), id...)
+	buf = append(buf, nonce...)
+	return aead.Seal(buf, nonce, val, []byte(id)), nil
+}
+
+func sealedWith(val []byte) (string, bool) {
+	if !bytes.HasPrefix(val, encPrefix) || len(val) < len(encPrefix)+encIDLen {
+		return "", false
+	}
+	return string(val[len(encPrefix) : len(encPrefix)+encIDLen]), true
+}
+
+func (k *keyring) open(val []byte) ([]byte, error) {
+	id, sealed := sealedWith(val)
+	if !sealed {
+		return val, nil
+	}
+	k.mtx.RLock()
+	aead, found := k.aeads[id]
+	k.mtx.RUnlock()
+	if !found {
+		return nil, ErrUnknownEncryptionKey
+	}
+	buf := val[len(encPrefix)+encIDLen:]
+	if len(buf) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	return aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], []byte(id))
+}
+
+// RotationProgress is kept in the local datastore while RotateEncryptionKey
+// runs, and after it completes.
+type RotationProgress struct {
+	Key     string
+	Rotated int
+	Done    bool
+}
+
+func (a *AntsDB) rotationKey() ds.Key {
+	return a.namespace.ChildString(rotationNs)
+}
+
+func (a *AntsDB) EncryptionRotation(ctx context.Context) (RotationProgress, error) {
+	p := RotationProgress{}
+	buf, err := a.storage.Get(ctx, a.rotationKey())
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(buf, &p)
+	return p, err
+}
+
+func (a *AntsDB) saveRotation(ctx context.Context, p RotationProgress) error {
+	buf, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return a.storage.Put(ctx, a.rotationKey(), buf)
+}
+
+// RotateEncryptionKey starts sealing new values with newKey and re-encrypts
+// every value under the namespace in the background. Old keys stay usable
+// for reads until all the values are rotated, and are forgotten afterwards.
+// Progress is saved locally; if the process stops before completion, the
+// rotation resumes on startup as long as newKey is given to
+// WithValueEncryption along with the old keys. Resuming scans the namespace
+// again, skipping values already sealed with newKey. Only one rotation runs
+// at a time; ErrRotationInProgress is returned until the current one ends.
+//
+// Rotation is local to this node. Every replica has to rotate as well:
+// values written by a replica which still uses an old key become unreadable
+// here once the rotation completes, and replicas without newKey cannot read
+// the values this node rotates. Re-encrypted values are ordinary writes, so
+// replicas rotating at the same time rewrite the same keys, and a rotation
+// racing with a write from a peer which has not arrived yet can overwrite
+// it. Rotate while writers are quiet and distribute the key to all nodes
+// first.
+func (a *AntsDB) RotateEncryptionKey(ctx context.Context, newKey []byte) error {
+	if a.keyring == nil {
+		return ErrEncryptionDisabled
+	}
+	if !a.keyring.startRotation() {
+		return ErrRotationInProgress
+	}
+	err := a.startRotation(ctx, newKey)
+	if err != nil {
+		a.keyring.endRotation()
+	}
+	return err
+}
+
+func (a *AntsDB) startRotation(ctx context.Context, newKey []byte) error {
+	if a.packer != nil {
+		// Packed values were sealed with the old key
+		err := a.flushPacked(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	// The key is only used for sealing once the rotation is persisted,
+	// values sealed with it before could not be read after a restart
+	id, fresh, err := a.keyring.add(newKey)
+	if err != nil {
+		return err
+	}
+	p := RotationProgress{Key: hex.EncodeToString([]byte(id))}
+	err = a.saveRotation(ctx, p)
+	if err != nil {
+		if fresh {
+			a.keyring.remove(id)
+		}
+		return err
+	}
+	a.keyring.use(id)
+	a.spawn(func() { a.rotate(p) })
+	return nil
+}
+
+func (a *AntsDB) resumeRotation() {
+	p, err := a.EncryptionRotation(a.ctx)
+	if err != nil {
+		if err != ds.ErrNotFound {
+			log.Errorf("Failed reading key rotation progress Err:%s", err.Error())
+		}
+		return
+	}
+	if p.Done {
+		return
+	}
+	id, err := hex.DecodeString(p.Key)
+	if err != nil || string(id) != a.keyring.current {
+		log.Errorf("Key rotation to %s not complete, the key is not configured as current", p.Key)
+		return
+	}
+	if !a.keyring.startRotation() {
+		return
+	}
+	a.spawn(func() { a.rotate(p) })
+}
+
+func (a *AntsDB) rotate(p RotationProgress) {
+	defer a.keyring.endRotation()
+
+	err := a.rotateValues(&p)
+	if err != nil {
+		log.Errorf("Key rotation stopped after %d values Err:%s", p.Rotated, err.Error())
+		return
+	}
+	p.Done = true
+	err = a.saveRotation(a.ctx, p)
+	if err != nil {
+		log.Errorf("Failed saving key rotation progress Err:%s", err.Error())
+		return
+	}
+	a.keyring.dropOld()
+	log.Infof("Key rotation complete, %d values re-encrypted", p.Rotated)
+}
+
+func (a *AntsDB) rotateValues(p *RotationProgress) error {
+	results, err := a.crdt.Query(a.ctx, query.Query{Prefix: "/"})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		rotated, err := a.rotateValue(ds.NewKey(r.Key), r.Value)
+		if err != nil {
+			return fmt.Errorf("rotating %s: %w", r.Key, err)
+		}
+		if !rotated {
+			continue
+		}
+		p.Rotated++
+		if p.Rotated%rotationSaveInterval == 0 {
+			err = a.saveRotation(a.ctx, *p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *AntsDB) rotateValue(key ds.Key, stored []byte) (bool, error) {
+	// Lazy values may have to be fetched, which must not hold off writes
+	val, err := a.materialize(a.ctx, stored)
+	if err != nil {
+		return false, err
+	}
+	id, sealed := sealedWith(val)
+	if !sealed || id == a.keyring.current {
+		return false, nil
+	}
+	plain, err := a.keyring.open(val)
+	if err != nil {
+		return false, err
+	}
+	val, err = a.encodeValue(a.ctx, plain)
+	if err != nil {
+		return false, err
+	}
+
+	// Hold off local writes so a newer value is not replaced by the old one
+	a.rotating.Lock()
+	defer a.rotating.Unlock()
+
+	current, err := a.crdt.Get(a.ctx, key)
+	if err != nil || !bytes.Equal(current, stored) {
+		// Changed since the scan started
+		return false, nil
+	}
+	return true, a.local.put(a.ctx, key, val)
+}
@@ -0,0 +1,139 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+)
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	adb, _ := makeTestingHost(t, WithValueEncryption(oldKey))
+	defer adb.Close()
+
+	for i := 0; i < 10; i++ {
+		err := adb.Put(context.TODO(), fmt.Sprintf("secret/%d", i), []byte("plaintext"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored, err := adb.crdt.Get(context.TODO(), ds.NewKey("secret/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("plaintext")) {
+		t.Fatal("value stored unencrypted")
+	}
+
+	// Keep the rotation from writing until the second one is refused
+	adb.rotating.Lock()
+	err = adb.RotateEncryptionKey(context.TODO(), newKey)
+	if err != nil {
+		adb.rotating.Unlock()
+		t.Fatal(err)
+	}
+	err = adb.RotateEncryptionKey(context.TODO(), bytes.Repeat([]byte{3}, 32))
+	adb.rotating.Unlock()
+	if err != ErrRotationInProgress {
+		t.Fatal("expected ErrRotationInProgress", err)
+	}
+	// Written with the new key while rotating
+	err = adb.Put(context.TODO(), "secret/new", []byte("plaintext"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	for {
+		p, err := adb.EncryptionRotation(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Done {
+			if p.Rotated != 10 {
+				t.Fatal("incorrect no of rotated values", p.Rotated)
+			}
+			break
+		}
+		if time.Since(started) > 5*time.Second {
+			t.Fatal("rotation did not complete")
+		}
+		<-time.After(50 * time.Millisecond)
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("secret/%d", i)
+		stored, err := adb.crdt.Get(context.TODO(), ds.NewKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id, _ := sealedWith(stored); id != encKeyID(newKey) {
+			t.Fatal("value not rotated", key)
+		}
+		val, err := adb.Get(context.TODO(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != "plaintext" {
+			t.Fatal("incorrect value after rotation")
+		}
+	}
+}
+
+func TestEncryptionDisabled(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	err := adb.RotateEncryptionKey(context.TODO(), bytes.Repeat([]byte{1}, 32))
+	if err != ErrEncryptionDisabled {
+		t.Fatal("expected error when encryption is not enabled", err)
+	}
+}
+
+type rotationFailStore struct {
+	ds.Batching
+}
+
+func (s *rotationFailStore) Put(ctx context.Context, key ds.Key, val []byte) error {
+	if key.BaseNamespace() == rotationNs {
+		return errors.New("rotation not saved")
+	}
+	return s.Batching.Put(ctx, key, val)
+}
+
+func TestKeyRotationNotSaved(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	storage := &rotationFailStore{syncds.MutexWrap(ds.NewMapDatastore())}
+	adb := newLocalAntsDB(t, storage, WithValueEncryption(oldKey))
+	defer adb.Close()
+
+	err := adb.RotateEncryptionKey(context.TODO(), newKey)
+	if err == nil {
+		t.Fatal("expected error when the rotation is not saved")
+	}
+	// The new key would be lost on restart, it must not be used
+	err = adb.Put(context.TODO(), "secret", []byte("plaintext"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := adb.crdt.Get(context.TODO(), ds.NewKey("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := sealedWith(stored); id != encKeyID(oldKey) {
+		t.Fatal("value sealed with the unsaved key")
+	}
+	if _, found := adb.keyring.aeads[encKeyID(newKey)]; found {
+		t.Fatal("unsaved key still registered")
+	}
+}
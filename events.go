@@ -0,0 +1,267 @@
+package antsdb
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"google.golang.org/protobuf/proto"
+)
+
+// KeyOp is a single change to a key within an Event
+type KeyOp struct {
+	Key     string
+	Value   []byte
+	Deleted bool
+}
+
+// Event groups the changes one CRDT delta made to the local replica. Deletes
+// come before puts, in the order the CRDT merges them.
+type Event struct {
+	Cid   cid.Cid
+	Local bool
+	Batch []KeyOp
+}
+
+type eventSub struct {
+	mtx    sync.Mutex
+	queue  []Event
+	notify chan struct{}
+}
+
+func (s *eventSub) push(ev Event) {
+	s.mtx.Lock()
+	s.queue = append(s.queue, ev)
+	s.mtx.Unlock()
+	signal(s.notify)
+}
+
+type events struct {
+	mtx  sync.Mutex
+	subs []*eventSub
+	// deltas seen but not merged yet, by block ID
+	deltas *lru.Cache
+}
+
+func (e *events) add(s *eventSub) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.deltas == nil {
+		e.deltas, _ = lru.New(conflictCacheSize)
+	}
+	e.subs = append(e.subs, s)
+}
+
+func (e *events) remove(s *eventSub) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	for i, v := range e.subs {
+		if v == s {
+			e.subs = append(e.subs[:i], e.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (e *events) active() bool {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	return len(e.subs) > 0
+}
+
+type pendingEvent struct {
+	cid   cid.Cid
+	delta *pb.Delta
+	local bool
+}
+
+func (a *AntsDB) stashDelta(nd ipld.Node, local bool) {
+	if !a.events.active() {
+		return
+	}
+	protoNode, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return
+	}
+	delta := &pb.Delta{}
+	if proto.Unmarshal(protoNode.Data(), delta) != nil {
+		return
+	}
+	a.events.deltas.Add(blockID(nd.Cid()), pendingEvent{cid: nd.Cid(), delta: delta, local: local})
+}
+
+// merged is called once the CRDT has merged the delta with the given block
+// ID, with the resulting state already stored.
+func (a *AntsDB) merged(ctx context.Context, id string) {
+	if !a.events.active() {
+		return
+	}
+	v, found := a.events.deltas.Get(id)
+	if !found {
+		return
+	}
+	a.events.deltas.Remove(id)
+	p := v.(pendingEvent)
+
+	ev := Event{Cid: p.cid, Local: p.local}
+	// A key has a tombstone for every version it had
+	deleted := map[string]struct{}{}
+	for _, t := range p.delta.GetTombstones() {
+		if _, found := deleted[t.GetKey()]; found || a.isMetadata(t.GetKey()) {
+			continue
+		}
+		deleted[t.GetKey()] = struct{}{}
+		ev.Batch = append(ev.Batch, KeyOp{Key: t.GetKey(), Deleted: true})
+	}
+	for _, e := range p.delta.GetElements() {
+		if a.isMetadata(e.GetKey()) {
+			continue
+		}
+		// Only report values which won over the current ones
+		cur, err := a.storage.Get(ctx, a.namespace.ChildString("s").
+			ChildString("k").ChildString(e.GetKey()).ChildString("v"))
+		if err != nil || string(cur) != string(e.GetValue()) {
+			continue
+		}
+		ev.Batch = append(ev.Batch, KeyOp{Key: e.GetKey(), Value: e.GetValue()})
+	}
+	if len(ev.Batch) == 0 {
+		return
+	}
+
+	a.events.mtx.Lock()
+	defer a.events.mtx.Unlock()
+
+	for _, s := range a.events.subs {
+		s.push(ev)
+	}
+}
+
+// eventStorage is the storage as seen by the CRDT. The CRDT records a delta
+// as processed right after merging it, which makes that write the point where
+// all of the delta has taken effect.
+type eventStorage struct {
+	ds.Batching
+	a *AntsDB
+}
+
+func (s *eventStorage) Put(ctx context.Context, key ds.Key, value []byte) error {
+	err := s.Batching.Put(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	processed := s.a.namespace.ChildString("b").String() + "/"
+	if id := key.String(); strings.HasPrefix(id, processed) {
+		id = strings.TrimPrefix(id, processed[:len(processed)-1])
+		if !strings.Contains(id[1:], "/") {
+			s.a.merged(ctx, id)
+		}
+	}
+	return nil
+}
+
+func (a *AntsDB) decodeOp(ctx context.Context, op KeyOp) (KeyOp, error) {
+	if op.Deleted {
+		return op, nil
+	}
+	val, err := a.decodeValue(ctx, op.Value)
+	if err != nil {
+		return op, err
+	}
+	if key, orig, hashed := splitHashedKey(val); hashed {
+		op.Key, val = ds.NewKey(key).String(), orig
+	}
+	op.Value = val
+	return op, nil
+}
+
+// Events emits one Event for every CRDT delta merged into the local replica
+// from now on, so a batch written by a peer can be processed as a unit.
+//
+// Local writes produce an event per commit: a Put is a batch of one, while a
+// Batch, a Tx or a flush of packed values is delivered whole. Remote deltas
+// are delivered as each one is merged; a peer's deltas fetched together may
+// be merged by several workers and arrive out of order. Writes which lose
+// against the current value of a key and the TTLs and pins AntsDB keeps
+// under ReservedPrefixes are left out, and deltas with nothing left are not
+// emitted.
+//
+// Events are queued for slow readers without bound. The channel is closed
+// once ctx is done or AntsDB is closed.
+func (a *AntsDB) Events(ctx context.Context) (<-chan Event, error) {
+	if a.ctx.Err() != nil {
+		return nil, a.ctx.Err()
+	}
+	if err := a.acquireWatcher(); err != nil {
+		return nil, err
+	}
+	s := &eventSub{notify: make(chan struct{}, 1)}
+	a.events.add(s)
+
+	done := func() {
+		a.events.remove(s)
+		a.releaseWatcher()
+	}
+	return a.streamEvents(ctx, s, done, func(ev Event) Event {
+		// Batches are shared by all subscribers
+		batch := make([]KeyOp, len(ev.Batch))
+		for i, op := range ev.Batch {
+			op, err := a.decodeOp(ctx, op)
+			if err != nil {
+				log.Errorf("Failed decoding %s for event Err:%s", op.Key, err.Error())
+			}
+			batch[i] = op
+		}
+		ev.Batch = batch
+		return ev
+	}), nil
+}
+
+// streamEvents hands the events queued for s to the returned channel, after
+// prepare, until ctx is done or AntsDB is closed. done is called on return.
+func (a *AntsDB) streamEvents(
+	ctx context.Context,
+	s *eventSub,
+	done func(),
+	prepare func(Event) Event,
+) <-chan Event {
+	res := make(chan Event)
+	a.spawn(func() {
+		defer close(res)
+		defer done()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.ctx.Done():
+				return
+			case <-s.notify:
+			}
+			s.mtx.Lock()
+			queue := s.queue
+			s.queue = nil
+			s.mtx.Unlock()
+
+			for _, ev := range queue {
+				select {
+				case res <- prepare(ev):
+				case <-ctx.Done():
+					return
+				case <-a.ctx.Done():
+					return
+				}
+			}
+		}
+	})
+	return res
+}
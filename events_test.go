@@ -0,0 +1,143 @@
+package antsdb
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	err := adb1.Put(context.TODO(), "old", []byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	local, err := adb1.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := adb2.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = adb1.Begin().
+		Put("a", []byte("a")).
+		Put("b", []byte("b")).
+		Delete("old").
+		Commit(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(ev Event, isLocal bool) {
+		t.Helper()
+		if ev.Local != isLocal {
+			t.Fatal("incorrect origin", ev.Local)
+		}
+		if len(ev.Batch) != 3 {
+			t.Fatal("expected the whole transaction in one event", ev.Batch)
+		}
+		// Deletes come first
+		if ev.Batch[0].Key != "/old" || !ev.Batch[0].Deleted {
+			t.Fatal("incorrect delete", ev.Batch[0])
+		}
+		puts := ev.Batch[1:]
+		sort.Slice(puts, func(i, j int) bool { return puts[i].Key < puts[j].Key })
+		for i, k := range []string{"a", "b"} {
+			if puts[i].Key != "/"+k || string(puts[i].Value) != k || puts[i].Deleted {
+				t.Fatal("incorrect put", puts[i])
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		events  <-chan Event
+		isLocal bool
+	}{{local, true}, {remote, false}} {
+		select {
+		case ev := <-c.events:
+			verify(ev, c.isLocal)
+		case <-ctx.Done():
+			t.Fatal("event not received")
+		}
+	}
+
+	cancel()
+	for range local {
+	}
+}
+
+func TestEventsSkipMetadata(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := adb.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.PutWithTTL(context.TODO(), "a", []byte("1"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Drops the TTL record
+	err = adb.Put(context.TODO(), "a", []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, val := range []string{"1", "2"} {
+		select {
+		case ev := <-events:
+			if len(ev.Batch) != 1 || ev.Batch[0].Key != "/a" || string(ev.Batch[0].Value) != val {
+				t.Fatal("expected only the put", ev.Batch)
+			}
+		case <-ctx.Done():
+			t.Fatal("put not reported", val)
+		}
+	}
+}
+
+func TestEventsHashedKeys(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithMaxKeyLength(32), WithKeyHashing())
+	defer adb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := adb.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("k", 64)
+	err = adb.Put(context.TODO(), long, []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if len(ev.Batch) != 1 || ev.Batch[0].Key != "/"+long || string(ev.Batch[0].Value) != "val" {
+			t.Fatal("expected the put under the original key", ev.Batch)
+		}
+	case <-ctx.Done():
+		t.Fatal("put not reported")
+	}
+}
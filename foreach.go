@@ -0,0 +1,123 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// TransformFunc turns a value read during an iteration into the one handed
+// to the caller.
+type TransformFunc func(key string, val []byte) ([]byte, error)
+
+// WithTransform applies fn to every value an iteration yields, after the
+// values are decoded from storage. Transforms given in several options run
+// in order, each on the result of the previous one. An error stops the
+// iteration and is returned wrapped with the key it failed on.
+func WithTransform(fn TransformFunc) OpOption {
+	return func(c *opConfig) {
+		c.transforms = append(c.transforms, fn)
+	}
+}
+
+func (c opConfig) transform(key string, val []byte) ([]byte, error) {
+	for _, fn := range c.transforms {
+		var err error
+		val, err = fn(key, val)
+		if err != nil {
+			return nil, fmt.Errorf("transforming %s: %w", key, err)
+		}
+	}
+	return val, nil
+}
+
+// ForEach calls fn with every live key under prefix and its value, in no
+// particular order, until fn returns an error, which is returned as is.
+// Values pass through WithTransform before reaching fn.
+func (a *AntsDB) ForEach(
+	ctx context.Context,
+	prefix string,
+	fn func(key string, val []byte) error,
+	opts ...OpOption,
+) error {
+	return a.scan(ctx, prefix, nil, newOpConfig(opts), func(key string, val []byte) (bool, error) {
+		return false, fn(key, val)
+	})
+}
+
+// scan visits the decoded values of the live keys under prefix which match,
+// if match is set, until visit asks to stop.
+func (a *AntsDB) scan(
+	ctx context.Context,
+	prefix string,
+	match func(string) bool,
+	cfg opConfig,
+	visit func(key string, val []byte) (bool, error),
+) error {
+	if a.packer != nil {
+		err := a.flushPacked(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	q := query.Query{Prefix: ds.NewKey(prefix).String()}
+	// Same as the datastore: a prefix only matches whole segments
+	under := q.Prefix
+	if under != "/" {
+		under += "/"
+	}
+	inPrefix := func(key string) bool { return strings.HasPrefix(key, under) }
+	if a.hashKeys {
+		// Hashed keys are stored away from their prefix
+		q.Prefix = "/"
+	}
+	results, err := a.crdt.Query(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		if a.isMetadata(r.Key) {
+			continue
+		}
+		hashed := ds.NewKey(r.Key).IsDescendantOf(ds.NewKey(hashedKeysNs))
+		if !hashed && (!inPrefix(r.Key) || (match != nil && !match(r.Key))) {
+			continue
+		}
+		val, err := a.decodeValue(ctx, r.Value)
+		if err != nil {
+			return err
+		}
+		key := r.Key
+		if hashed {
+			orig, v, ok := splitHashedKey(val)
+			if !ok || !inPrefix(orig) || (match != nil && !match(orig)) {
+				continue
+			}
+			key, val = orig, v
+		}
+		expired, err := a.isExpired(ctx, r.Key)
+		if err != nil {
+			return err
+		}
+		if expired {
+			continue
+		}
+		val, err = cfg.transform(key, val)
+		if err != nil {
+			return err
+		}
+		stop, err := visit(key, val)
+		if err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestForEachTransform(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithMaxKeyLength(32), WithKeyHashing())
+	defer adb.Close()
+
+	for _, k := range []string{"/a/1", "/a/2", "/ab/3", "/b/" + strings.Repeat("x", 64)} {
+		err := adb.Put(context.TODO(), k, []byte("val"+k))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := adb.Put(context.TODO(), "/a/"+strings.Repeat("y", 64), []byte("long"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upper := func(key string, val []byte) ([]byte, error) { return bytes.ToUpper(val), nil }
+	suffix := func(key string, val []byte) ([]byte, error) { return append(val, '!'), nil }
+
+	seen := map[string]string{}
+	err = adb.ForEach(context.TODO(), "/a", func(key string, val []byte) error {
+		seen[key] = string(val)
+		return nil
+	}, WithTransform(upper), WithTransform(suffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 3 {
+		t.Fatal("incorrect keys", seen)
+	}
+	if seen["/a/1"] != "VAL/A/1!" || seen["/a/"+strings.Repeat("y", 64)] != "LONG!" {
+		t.Fatal("values not transformed", seen)
+	}
+
+	errBad := errors.New("bad value")
+	calls := 0
+	err = adb.ForEach(context.TODO(), "/", func(key string, val []byte) error {
+		calls++
+		return nil
+	}, WithTransform(func(key string, val []byte) ([]byte, error) {
+		if key == "/a/2" {
+			return nil, errBad
+		}
+		return val, nil
+	}))
+	if !errors.Is(err, errBad) || !strings.Contains(err.Error(), "/a/2") {
+		t.Fatal("expected wrapped transform error", err)
+	}
+	if calls > 4 {
+		t.Fatal("iteration not stopped", calls)
+	}
+
+	kvs, err := adb.ListBySuffix(context.TODO(), "/3", 0, WithTransform(upper))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 1 || string(kvs[0].Value) != "VAL/AB/3" {
+		t.Fatal("incorrect suffix listing", kvs)
+	}
+}
@@ -0,0 +1,205 @@
+package antsdb
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	dag "github.com/ipfs/go-merkledag"
+	"google.golang.org/protobuf/proto"
+)
+
+var pinsNs = "/_pins"
+
+func pinKey(key string) ds.Key {
+	return ds.NewKey(pinsNs).Child(ds.NewKey(key))
+}
+
+// PinKeys protects every value block ever referenced by the keys from GC,
+// not just the current ones, so peers lagging behind can still resolve
+// older values. Pins are replicated like any other key, so all the nodes
+// honour them.
+func (a *AntsDB) PinKeys(ctx context.Context, keys []string) error {
+	batch, err := a.local.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		err = batch.Put(ctx, pinKey(k), []byte{})
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Commit(ctx)
+}
+
+func (a *AntsDB) UnpinKeys(ctx context.Context, keys []string) error {
+	batch, err := a.local.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		err = batch.Delete(ctx, pinKey(k))
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Commit(ctx)
+}
+
+func (a *AntsDB) pinnedKeys(ctx context.Context) (map[string]struct{}, error) {
+	results, err := a.crdt.Query(ctx, query.Query{Prefix: pinsNs, KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	pinned := make(map[string]struct{})
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		pinned[strings.TrimPrefix(r.Key, pinsNs)] = struct{}{}
+	}
+	return pinned, nil
+}
+
+// gcState remembers the blocks found unreferenced by the previous GC
+type gcState struct {
+	// Updated atomically, see AntsDB
+	runs       uint64
+	removed    uint64
+	mtx        sync.Mutex
+	candidates map[string]struct{}
+}
+
+// GC removes the value blocks written by WithLazyMaterialization which are
+// no longer referenced by any key, and returns how many were removed. A
+// block is only removed once two consecutive runs find it unreferenced, so
+// a block added by a write still in progress during one run survives until
+// the write has committed; the first run after startup removes nothing.
+// DAG deltas are never removed, as replicas need the whole history to
+// converge. WithProgress reports the blocks examined.
+func (a *AntsDB) GC(ctx context.Context, opts ...OpOption) (int, error) {
+	cfg := newOpConfig(opts)
+
+	a.gc.mtx.Lock()
+	defer a.gc.mtx.Unlock()
+
+	if a.packer != nil {
+		err := a.flushPacked(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	referenced, err := a.referencedBlocks(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := -1
+	if cfg.progress != nil {
+		total, err = a.blockCount(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	prog := cfg.tracker(total)
+
+	keys, err := a.blocks.AllKeysChan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	candidates := make(map[string]struct{})
+	removed := 0
+	for c := range keys {
+		prog.step()
+		// The blockstore only keeps multihashes
+		if _, found := referenced[string(c.Hash())]; found {
+			continue
+		}
+		isDelta, err := a.isDeltaBlock(ctx, c)
+		if err != nil {
+			return removed, err
+		}
+		if isDelta {
+			continue
+		}
+		if _, found := a.gc.candidates[string(c.Hash())]; !found {
+			candidates[string(c.Hash())] = struct{}{}
+			continue
+		}
+		err = a.blocks.DeleteBlock(ctx, c)
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	if ctx.Err() != nil {
+		return removed, ctx.Err()
+	}
+	a.gc.candidates = candidates
+	atomic.AddUint64(&a.gc.runs, 1)
+	atomic.AddUint64(&a.gc.removed, uint64(removed))
+	prog.finish()
+	log.Infof("GC removed %d blocks, %d to be removed next run", removed, len(candidates))
+	return removed, nil
+}
+
+// isDeltaBlock errs on the side of keeping values which happen to decode
+// like a delta.
+func (a *AntsDB) isDeltaBlock(ctx context.Context, c cid.Cid) (bool, error) {
+	blk, err := a.blocks.Get(ctx, c)
+	if err != nil {
+		return false, err
+	}
+	nd, err := dag.DecodeProtobuf(blk.RawData())
+	if err != nil {
+		return false, nil
+	}
+	return proto.Unmarshal(nd.Data(), &pb.Delta{}) == nil, nil
+}
+
+func (a *AntsDB) referencedBlocks(ctx context.Context) (map[string]struct{}, error) {
+	results, err := a.crdt.Query(ctx, query.Query{Prefix: "/"})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	referenced := make(map[string]struct{})
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if c, isRef := lazyRef(r.Value); isRef {
+			referenced[string(c.Hash())] = struct{}{}
+		}
+	}
+
+	pinned, err := a.pinnedKeys(ctx)
+	if err != nil || len(pinned) == 0 {
+		return referenced, err
+	}
+	heads, err := a.currentHeads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = a.walkDAG(ctx, heads, func(_ cid.Cid, delta *pb.Delta) error {
+		for _, e := range delta.GetElements() {
+			if _, found := pinned[e.GetKey()]; !found {
+				continue
+			}
+			if c, isRef := lazyRef(e.GetValue()); isRef {
+				referenced[string(c.Hash())] = struct{}{}
+			}
+		}
+		return nil
+	})
+	return referenced, err
+}
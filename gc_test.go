@@ -0,0 +1,65 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGC(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithLazyMaterialization())
+	defer adb.Close()
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		for _, k := range []string{"plain", "pinned"} {
+			err := adb.Put(context.TODO(), k, []byte(k+v))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err := adb.PinKeys(context.TODO(), []string{"pinned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := adb.GC(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Fatal("first run should only mark blocks", removed)
+	}
+	removed, err = adb.GC(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Older values of the pinned key are kept
+	if removed != 2 {
+		t.Fatal("incorrect no of blocks removed", removed)
+	}
+	for _, k := range []string{"plain", "pinned"} {
+		val, err := adb.Get(context.TODO(), k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != k+"v3" {
+			t.Fatal("incorrect value after GC", string(val))
+		}
+	}
+
+	err = adb.UnpinKeys(context.TODO(), []string{"pinned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.GC(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err = adb.GC(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatal("unpinned blocks not removed", removed)
+	}
+}
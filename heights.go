@@ -0,0 +1,75 @@
+package antsdb
+
+import (
+	"context"
+	"sort"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+)
+
+// KeysInHeightRange returns the keys put or deleted by the deltas whose CRDT
+// height is between minHeight and maxHeight, both included. Heights follow
+// causality: a delta is always higher than every delta it builds on, while
+// concurrent deltas written on different replicas can share a height.
+//
+// The DAG is walked from the current heads down to minHeight, so the cost
+// grows with everything written since minHeight, not only with the range.
+// Deltas are never garbage collected, but missing blocks are fetched from
+// peers and the call fails if none of them has a block anymore. TTL and pin
+// metadata is left out. Keys hashed by WithKeyHashing are reported as
+// written, except for deletes, which only carry the hashed form.
+func (a *AntsDB) KeysInHeightRange(ctx context.Context, minHeight, maxHeight uint64) ([]string, error) {
+	if a.packer != nil {
+		err := a.flushPacked(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	heads, err := a.currentHeads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]struct{})
+	err = a.walkDAG(ctx, heads, func(c cid.Cid, delta *pb.Delta) error {
+		height := delta.GetPriority()
+		if height < minHeight {
+			// Everything below is lower still
+			return errSkipLinks
+		}
+		if height > maxHeight {
+			return nil
+		}
+		for _, e := range delta.GetElements() {
+			key := e.GetKey()
+			if ds.NewKey(key).IsDescendantOf(ds.NewKey(hashedKeysNs)) {
+				val, err := a.decodeValue(ctx, e.GetValue())
+				if err != nil {
+					return err
+				}
+				if orig, _, ok := splitHashedKey(val); ok {
+					key = orig
+				}
+			}
+			keys[key] = struct{}{}
+		}
+		for _, t := range delta.GetTombstones() {
+			keys[t.GetKey()] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(keys))
+	for k := range keys {
+		if !a.isMetadata(k) {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
@@ -0,0 +1,54 @@
+package antsdb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeysInHeightRange(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	ctx := context.TODO()
+	// Every write below is a delta one level higher
+	for _, k := range []string{"k1", "k2"} {
+		err := adb.Put(ctx, k, []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := adb.Begin().Put("k3", []byte("val")).Put("k4", []byte("val")).Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.DeleteKey(ctx, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.PutWithTTL(ctx, "k5", []byte("val"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		min, max uint64
+		keys     []string
+	}{
+		{1, 1, []string{"/k1"}},
+		{2, 3, []string{"/k2", "/k3", "/k4"}},
+		{4, 4, []string{"/k1"}},
+		{5, 10, []string{"/k5"}},
+		{6, 10, []string{}},
+		{1, 5, []string{"/k1", "/k2", "/k3", "/k4", "/k5"}},
+	} {
+		keys, err := adb.KeysInHeightRange(ctx, tc.min, tc.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(keys, tc.keys) {
+			t.Fatalf("incorrect keys in [%d, %d]: %v", tc.min, tc.max, keys)
+		}
+	}
+}
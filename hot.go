@@ -0,0 +1,93 @@
+package antsdb
+
+import (
+	"context"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	hotRebroadcastDelay = 200 * time.Millisecond
+	hotRebroadcastTimes = 5
+)
+
+// WithHotPrefixes rebroadcasts the heads right after a local write to any key
+// under one of the prefixes, and a few more times with a growing delay, so
+// peers pick up changes to latency sensitive keys without waiting for the
+// regular rebroadcast interval. Everything else keeps the configured
+// interval, which can then be set high to save bandwidth.
+//
+// All keys share one CRDT DAG, so the extra broadcasts announce the heads of
+// the whole datastore: a cold write which happens to be pending is delivered
+// along with the hot one. There is no way to rebroadcast a prefix alone.
+func WithHotPrefixes(prefixes ...string) Option {
+	return func(a *AntsDB) {
+		for _, p := range prefixes {
+			a.hotPrefixes = append(a.hotPrefixes, ds.NewKey(p))
+		}
+		a.hot = make(chan struct{}, 1)
+	}
+}
+
+func (a *AntsDB) isHot(keys []ds.Key) bool {
+	for _, k := range keys {
+		for _, p := range a.hotPrefixes {
+			if k.Equal(p) || k.IsDescendantOf(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *AntsDB) broadcastHeads(ctx context.Context) error {
+	heads, err := a.currentHeads(ctx)
+	if err != nil {
+		return err
+	}
+	if len(heads) == 0 {
+		return nil
+	}
+	bcast := &pb.CRDTBroadcast{}
+	for _, h := range heads {
+		bcast.Heads = append(bcast.Heads, &pb.Head{Cid: h.Bytes()})
+	}
+	data, err := proto.Marshal(bcast)
+	if err != nil {
+		return err
+	}
+	return a.bcast.Broadcast(data)
+}
+
+// hotRebroadcast restarts the backoff on every hot write, so a burst of
+// writes is announced as a single series of broadcasts.
+func (a *AntsDB) hotRebroadcast() {
+	var (
+		next  <-chan time.Time
+		delay time.Duration
+		left  int
+	)
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-a.hot:
+			delay, left = hotRebroadcastDelay, hotRebroadcastTimes
+			next = time.After(0)
+		case <-next:
+			err := a.broadcastHeads(a.ctx)
+			if err != nil {
+				log.Errorf("Failed rebroadcasting hot heads Err:%s", err.Error())
+			}
+			next = nil
+			left--
+			if left > 0 {
+				next = time.After(delay)
+				delay *= 2
+			}
+		}
+	}
+}
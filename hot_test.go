@@ -0,0 +1,48 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHotPrefixes(t *testing.T) {
+	slow := WithRebroadcastDuration(time.Minute)
+
+	hot1, h1 := makeTestingHost(t, slow, WithChannel("hot"), WithHotPrefixes("/hot"))
+	defer hot1.Close()
+	hot2, h2 := makeTestingHost(t, slow, WithChannel("hot"))
+	defer hot2.Close()
+
+	cold1, h3 := makeTestingHost(t, slow, WithChannel("cold"), WithHotPrefixes("/hot"))
+	defer cold1.Close()
+	cold2, h4 := makeTestingHost(t, slow, WithChannel("cold"))
+	defer cold2.Close()
+
+	// Writes happen before the peers are connected, so the broadcast sent
+	// along with them is lost
+	err := hot1.Put(context.TODO(), "hot/1", []byte("hot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cold1.Put(context.TODO(), "cold/1", []byte("cold"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connectHosts(t, h1, h2)
+	connectHosts(t, h3, h4)
+
+	<-time.After(time.Second * 4)
+
+	val, err := hot2.Get(context.TODO(), "hot/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "hot" {
+		t.Fatal("incorrect value for hot key")
+	}
+	_, err = cold2.Get(context.TODO(), "cold/1")
+	if err == nil {
+		t.Fatal("cold key synced before rebroadcast interval")
+	}
+}
@@ -0,0 +1,114 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var (
+	hashedKeysNs = "/_h"
+	// Values of hashed keys start with this marker and the original key
+	hashedKeyPrefix = []byte("\x00antsdb/key\x00")
+
+	ErrKeyTooLong        = errors.New("key exceeds the maximum length")
+	ErrKeyHashingNoLimit = errors.New("WithKeyHashing requires WithMaxKeyLength")
+)
+
+// WithMaxKeyLength rejects keys longer than n bytes in the key-value methods
+// with ErrKeyTooLong, instead of leaving it to the backend to fail.
+func WithMaxKeyLength(n int) Option {
+	return func(a *AntsDB) {
+		a.maxKeyLen = n
+	}
+}
+
+// WithKeyHashing stores keys longer than the WithMaxKeyLength limit under a
+// hash of the key instead of rejecting them; New fails with
+// ErrKeyHashingNoLimit without a limit. The original key is kept in
+// the value so reads can tell hash collisions apart. Hashed keys do not
+// share a prefix with the key they replace, so they are missed by prefix
+// queries like Diff and are reported with their hash to subscribers.
+// WatchWhere, Events and the change log report their puts under the
+// original key, but deletes under the hash, as only the value holds it.
+func WithKeyHashing() Option {
+	return func(a *AntsDB) {
+		a.hashKeys = true
+	}
+}
+
+// storedKey returns the key used in the CRDT for key
+func (a *AntsDB) storedKey(key string) (string, bool, error) {
+	if a.maxKeyLen == 0 || len(key) <= a.maxKeyLen {
+		return key, false, nil
+	}
+	if !a.hashKeys {
+		return "", false, ErrKeyTooLong
+	}
+	sum := sha256.Sum256([]byte(key))
+	return ds.NewKey(hashedKeysNs).ChildString(hex.EncodeToString(sum[:])).String(), true, nil
+}
+
+func wrapHashedKey(key string, val []byte) []byte {
+	n := make([]byte, binary.MaxVarintLen64)
+	buf := append([]byte(nil), hashedKeyPrefix...)
+	buf = append(buf, n[:binary.PutUvarint(n, uint64(len(key)))]...)
+	buf = append(buf, key...)
+	return append(buf, val...)
+}
+
+// splitHashedKey recovers the original key of values stored with
+// WithKeyHashing
+func splitHashedKey(val []byte) (string, []byte, bool) {
+	if !bytes.HasPrefix(val, hashedKeyPrefix) {
+		return "", val, false
+	}
+	rest := val[len(hashedKeyPrefix):]
+	n, read := binary.Uvarint(rest)
+	if read <= 0 || uint64(len(rest)-read) < n {
+		return "", val, false
+	}
+	rest = rest[read:]
+	return string(rest[:n]), rest[n:], true
+}
+
+// unwrapHashedKey returns false if val was not written for key
+func unwrapHashedKey(key string, val []byte) ([]byte, bool) {
+	orig, val, hashed := splitHashedKey(val)
+	if !hashed || orig != key {
+		return nil, false
+	}
+	return val, true
+}
+
+func (a *AntsDB) encodeEntry(ctx context.Context, key string, val []byte) (string, []byte, error) {
+	if err := a.checkReserved(key); err != nil {
+		return "", nil, err
+	}
+	stored, hashed, err := a.storedKey(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if hashed {
+		val = wrapHashedKey(key, val)
+	}
+	val, err = a.encodeValue(ctx, val)
+	return stored, val, err
+}
+
+func (a *AntsDB) decodeEntry(ctx context.Context, key string, hashed bool, val []byte) ([]byte, error) {
+	val, err := a.decodeValue(ctx, val)
+	if err != nil || !hashed {
+		return val, err
+	}
+	val, ok := unwrapHashedKey(key, val)
+	if !ok {
+		return nil, ds.ErrNotFound
+	}
+	return val, nil
+}
@@ -0,0 +1,90 @@
+package antsdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestMaxKeyLength(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithMaxKeyLength(16))
+	defer adb.Close()
+
+	long := strings.Repeat("k", 17)
+	err := adb.Put(context.TODO(), long, []byte("val"))
+	if err != ErrKeyTooLong {
+		t.Fatal("expected ErrKeyTooLong on Put", err)
+	}
+	_, err = adb.Get(context.TODO(), long)
+	if err != ErrKeyTooLong {
+		t.Fatal("expected ErrKeyTooLong on Get", err)
+	}
+	err = adb.DeleteKey(context.TODO(), long)
+	if err != ErrKeyTooLong {
+		t.Fatal("expected ErrKeyTooLong on DeleteKey", err)
+	}
+	err = adb.Put(context.TODO(), "short", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyHashing(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithMaxKeyLength(16), WithKeyHashing())
+	defer adb.Close()
+
+	long := "/" + strings.Repeat("k", 100)
+	err := adb.Put(context.TODO(), long, []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.crdt.Get(context.TODO(), ds.NewKey(long))
+	if err != ds.ErrNotFound {
+		t.Fatal("long key stored as is", err)
+	}
+	val, err := adb.Get(context.TODO(), long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value for hashed key", string(val))
+	}
+	val, err = adb.GetAtHead(context.TODO(), long, singleHead(t, adb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value for hashed key at head", string(val))
+	}
+
+	err = adb.PutWithTTL(context.TODO(), long, []byte("ttl"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err = adb.GetRefreshTTL(context.TODO(), long, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "ttl" {
+		t.Fatal("incorrect value for hashed key", string(val))
+	}
+
+	err = adb.DeleteKey(context.TODO(), long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.Get(context.TODO(), long)
+	if err != ds.ErrNotFound {
+		t.Fatal("hashed key not deleted", err)
+	}
+}
+
+func TestKeyHashingNoLimit(t *testing.T) {
+	_, err := New(nil, nil, nil, nil, WithKeyHashing())
+	if err != ErrKeyHashingNoLimit {
+		t.Fatal("expected ErrKeyHashingNoLimit", err)
+	}
+}
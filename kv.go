@@ -0,0 +1,135 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+
+	ds "github.com/ipfs/go-datastore"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func (a *AntsDB) Put(ctx context.Context, key string, val []byte) error {
+	ctx, span := a.startSpan(ctx, "Put", attribute.String("key", key), attribute.Int("size", len(val)))
+	err := a.put(ctx, key, val, nil)
+	endSpan(span, err)
+	return err
+}
+
+// put collects the deltas of the write in token if it is set. Such writes
+// are not packed, as the delta of a packed value is only known once flushed.
+func (a *AntsDB) put(ctx context.Context, key string, val []byte, token *Token) error {
+	key, val, err := a.encodeEntry(ctx, key, val)
+	if err != nil {
+		return err
+	}
+	if a.packer != nil {
+		if token == nil && len(val) <= a.packer.threshold {
+			return a.packValue(ctx, key, val)
+		}
+		a.packer.drop(key)
+	}
+	batch := &localBatch{a: a, token: token}
+	err = batch.Put(ctx, ds.NewKey(key), val)
+	if err != nil {
+		return err
+	}
+	// A plain Put stores the value without expiry
+	err = batch.Delete(ctx, ttlKey(key))
+	if err != nil {
+		return err
+	}
+	return batch.Commit(ctx)
+}
+
+func (a *AntsDB) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := a.checkStale(); err != nil {
+		return nil, err
+	}
+	ctx, span := a.startSpan(ctx, "Get", attribute.String("key", key))
+	val, err := a.get(ctx, key)
+	span.SetAttributes(attribute.Int("size", len(val)))
+	endSpan(span, err)
+	return val, err
+}
+
+func (a *AntsDB) get(ctx context.Context, key string) ([]byte, error) {
+	stored, hashed, err := a.storedKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if a.packer != nil {
+		if val, found := a.packer.get(stored); found {
+			return a.decodeEntry(ctx, key, hashed, val)
+		}
+	}
+	expired, err := a.isExpired(ctx, stored)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ds.ErrNotFound
+	}
+	val, err := a.crdt.Get(ctx, ds.NewKey(stored))
+	if err != nil {
+		return nil, err
+	}
+	return a.decodeEntry(ctx, key, hashed, val)
+}
+
+func (a *AntsDB) DeleteKey(ctx context.Context, key string) error {
+	ctx, span := a.startSpan(ctx, "Delete", attribute.String("key", key))
+	err := a.deleteKey(ctx, key, nil)
+	endSpan(span, err)
+	return err
+}
+
+func (a *AntsDB) deleteKey(ctx context.Context, key string, token *Token) error {
+	if err := a.checkReserved(key); err != nil {
+		return err
+	}
+	key, _, err := a.storedKey(key)
+	if err != nil {
+		return err
+	}
+	return a.deleteStored(ctx, key, token)
+}
+
+func (a *AntsDB) deleteStored(ctx context.Context, key string, token *Token) error {
+	if a.packer != nil {
+		a.packer.drop(key)
+	}
+	batch := &localBatch{a: a, token: token}
+	err := batch.Delete(ctx, ds.NewKey(key))
+	if err != nil {
+		return err
+	}
+	err = batch.Delete(ctx, ttlKey(key))
+	if err != nil {
+		return err
+	}
+	return batch.Commit(ctx)
+}
+
+// DeleteIfEquals deletes the key only if its current value matches expected
+// and reports whether the delete happened. The check is done against the
+// local replica: a concurrent write on this node or on another replica may
+// land between the read and the delete, and replicas which already merged a
+// newer value will keep it only if it wins over the delete in the CRDT. Like
+// any read-modify-write over a CRDT this is a best effort guard, not a lock.
+func (a *AntsDB) DeleteIfEquals(ctx context.Context, key string, expected []byte) (bool, error) {
+	val, err := a.Get(ctx, key)
+	if err != nil {
+		if err == ds.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if !bytes.Equal(val, expected) {
+		return false, nil
+	}
+	err = a.DeleteKey(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
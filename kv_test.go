@@ -0,0 +1,72 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestKVCRUD(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	err := adb.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := adb.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value", string(val))
+	}
+	err = adb.DeleteKey(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.Get(context.TODO(), "key")
+	if err != ds.ErrNotFound {
+		t.Fatal("able to read key after delete", err)
+	}
+}
+
+func TestDeleteIfEquals(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	err := adb.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := adb.DeleteIfEquals(context.TODO(), "key", []byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted {
+		t.Fatal("deleted key with mismatched value")
+	}
+	_, err = adb.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal("key removed on mismatch", err)
+	}
+
+	deleted, err = adb.DeleteIfEquals(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deleted {
+		t.Fatal("key not deleted on match")
+	}
+	_, err = adb.Get(context.TODO(), "key")
+	if err != ds.ErrNotFound {
+		t.Fatal("able to read key after delete", err)
+	}
+
+	deleted, err = adb.DeleteIfEquals(context.TODO(), "key", []byte("val"))
+	if err != nil || deleted {
+		t.Fatal("delete reported for missing key", deleted, err)
+	}
+}
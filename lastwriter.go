@@ -0,0 +1,124 @@
+package antsdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	ds "github.com/ipfs/go-datastore"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+var writtenByNs = "_writers"
+
+var ErrWriterTrackingDisabled = errors.New("writer tracking is not enabled")
+
+// WithWriterTracking records which peer wrote the current value of every key,
+// answered by LastWriter. The record is a local index kept next to the CRDT
+// state, costing one entry of roughly the key length plus 40 bytes per key; it
+// is not replicated, every replica builds its own.
+//
+// Remote deltas are credited to the peer which first announced them, as
+// reported by Writers. A delta that was never announced on its own, e.g. one
+// only reachable through a later head, is credited to the announcer of the
+// head it was fetched through, which may not be its author.
+func WithWriterTracking() Option {
+	return func(a *AntsDB) {
+		a.lastWriters = newLastWriters()
+	}
+}
+
+type lastWriters struct {
+	mtx sync.Mutex
+	// peer of each element seen in a delta, until the put hook stores it
+	pending *lru.Cache
+}
+
+func newLastWriters() *lastWriters {
+	pending, _ := lru.New(conflictCacheSize)
+	return &lastWriters{pending: pending}
+}
+
+func pendingWriteKey(key string, val []byte) string {
+	sum := sha256.Sum256(val)
+	return key + "\x00" + string(sum[:])
+}
+
+func (a *AntsDB) writtenByKey(key string) ds.Key {
+	return a.namespace.ChildString(writtenByNs).Child(ds.NewKey(key))
+}
+
+// attributeDelta remembers the writer of the elements in a delta, so the put
+// hook can record it if one of them becomes the current value.
+func (a *AntsDB) attributeDelta(w *walk, nd ipld.Node) {
+	protoNode, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return
+	}
+	delta := &pb.Delta{}
+	if proto.Unmarshal(protoNode.Data(), delta) != nil {
+		return
+	}
+
+	lw := a.lastWriters
+	lw.mtx.Lock()
+	defer lw.mtx.Unlock()
+
+	writer := a.host.ID()
+	if w != nil {
+		if from, found := a.writers.seen.Peek(nd.Cid()); found {
+			w.writer = from.(peer.ID)
+		}
+		writer = w.writer
+	}
+	if writer == "" {
+		return
+	}
+	for _, e := range delta.GetElements() {
+		lw.pending.Add(pendingWriteKey(e.GetKey(), e.GetValue()), writer)
+	}
+}
+
+func (a *AntsDB) recordWriter(ctx context.Context, key string, val []byte) {
+	from, found := a.lastWriters.pending.Get(pendingWriteKey(key, val))
+	if !found {
+		// Rather no answer than a stale one
+		a.forgetWriter(ctx, key)
+		return
+	}
+	err := a.storage.Put(ctx, a.writtenByKey(key), []byte(from.(peer.ID)))
+	if err != nil {
+		log.Errorf("Failed recording writer of %s Err:%s", key, err.Error())
+	}
+}
+
+func (a *AntsDB) forgetWriter(ctx context.Context, key string) {
+	err := a.storage.Delete(ctx, a.writtenByKey(key))
+	if err != nil {
+		log.Errorf("Failed removing writer of %s Err:%s", key, err.Error())
+	}
+}
+
+// LastWriter returns the peer which wrote the current value of key. It
+// returns ds.ErrNotFound if the key has no value or its writer is unknown,
+// for instance because it was written before WithWriterTracking was enabled.
+func (a *AntsDB) LastWriter(ctx context.Context, key string) (peer.ID, error) {
+	if a.lastWriters == nil {
+		return "", ErrWriterTrackingDisabled
+	}
+	stored, _, err := a.storedKey(key)
+	if err != nil {
+		return "", err
+	}
+	buf, err := a.storage.Get(ctx, a.writtenByKey(stored))
+	if err != nil {
+		return "", err
+	}
+	return peer.IDFromBytes(buf)
+}
@@ -0,0 +1,83 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestLastWriter(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithWriterTracking())
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t, WithWriterTracking())
+	defer adb2.Close()
+
+	adb3, h3 := makeTestingHost(t, WithWriterTracking())
+	defer adb3.Close()
+
+	connectHosts(t, h1, h2, h3)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	waitWriter := func(adb *AntsDB, key string, want peer.ID) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for {
+			got, err := adb.LastWriter(ctx, key)
+			if err == nil && got == want {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("expected %s to be written by %s, got %s %v", key, want, got, err)
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	err := adb1.Put(context.TODO(), "key1", []byte("val1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb2.Put(context.TODO(), "key2", []byte("val2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, adb := range []*AntsDB{adb1, adb2, adb3} {
+		waitWriter(adb, "key1", h1.ID())
+		waitWriter(adb, "key2", h2.ID())
+	}
+
+	// Overwrite moves the attribution
+	err = adb2.Put(context.TODO(), "key1", []byte("val3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitWriter(adb1, "key1", h2.ID())
+	waitWriter(adb3, "key1", h2.ID())
+
+	err = adb2.DeleteKey(context.TODO(), "key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(time.Second * 2)
+	_, err = adb1.LastWriter(context.TODO(), "key1")
+	if err == nil {
+		t.Fatal("expected no writer for deleted key")
+	}
+}
+
+func TestLastWriterDisabled(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	_, err := adb.LastWriter(context.TODO(), "key")
+	if err != ErrWriterTrackingDisabled {
+		t.Fatal("expected ErrWriterTrackingDisabled", err)
+	}
+}
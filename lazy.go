@@ -0,0 +1,86 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+
+	cid "github.com/ipfs/go-cid"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+// Values stored lazily are replaced in the CRDT by this marker followed by
+// the CID of the raw block holding the value.
+var lazyRefPrefix = []byte("\x00antsdb/ref\x00")
+
+// WithLazyMaterialization keeps values in the DAG only. Put adds the value as
+// a separate raw block and replicates just a reference to it, so replicas do
+// not download values until they are read. The first Get of a key on a
+// replica fetches the block from peers, which can take as long as a DAG
+// fetch (up to the DAG syncer timeout); afterwards the block is served from
+// the local blockstore.
+//
+// References written by other replicas are always resolved on read, whether
+// or not this option is set locally.
+func WithLazyMaterialization() Option {
+	return func(a *AntsDB) {
+		a.lazy = true
+	}
+}
+
+func (a *AntsDB) encodeValue(ctx context.Context, val []byte) ([]byte, error) {
+	if a.keyring != nil {
+		var err error
+		val, err = a.keyring.seal(val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !a.lazy {
+		return val, nil
+	}
+	nd := dag.NewRawNode(val)
+	err := a.syncer.Add(ctx, nd)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), lazyRefPrefix...), nd.Cid().Bytes()...), nil
+}
+
+func lazyRef(val []byte) (cid.Cid, bool) {
+	if !bytes.HasPrefix(val, lazyRefPrefix) {
+		return cid.Undef, false
+	}
+	c, err := cid.Cast(val[len(lazyRefPrefix):])
+	if err != nil {
+		return cid.Undef, false
+	}
+	return c, true
+}
+
+func (a *AntsDB) decodeValue(ctx context.Context, val []byte) ([]byte, error) {
+	val, err := a.materialize(ctx, val)
+	if err != nil {
+		return nil, err
+	}
+	if a.keyring == nil {
+		return val, nil
+	}
+	return a.keyring.open(val)
+}
+
+// materialize resolves lazy references to the stored value
+func (a *AntsDB) materialize(ctx context.Context, val []byte) ([]byte, error) {
+	c, isRef := lazyRef(val)
+	if !isRef {
+		return val, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.dagTimeout)
+	defer cancel()
+
+	nd, err := a.syncer.Get(ctx, c)
+	if err != nil {
+		log.Errorf("Failed materializing value %s Err:%s", c, err.Error())
+		return nil, err
+	}
+	return nd.RawData(), nil
+}
@@ -0,0 +1,60 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	ipfslite "github.com/hsanjuan/ipfs-lite"
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestLazyMaterialization(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithLazyMaterialization())
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+
+	val := bytes.Repeat([]byte("cold"), 1024)
+	err := adb1.Put(context.TODO(), "cold/1", val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Allow update to propogate
+	<-time.After(time.Second * 3)
+
+	ref, err := adb2.crdt.Get(context.TODO(), ds.NewKey("cold/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, isRef := lazyRef(ref)
+	if !isRef {
+		t.Fatal("expected only a reference to be replicated")
+	}
+	has, err := adb2.syncer.(*ipfslite.Peer).HasBlock(context.TODO(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Fatal("value block fetched before first read")
+	}
+
+	got, err := adb2.Get(context.TODO(), "cold/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, val) {
+		t.Fatal("incorrect value after materializing")
+	}
+	has, err = adb2.syncer.(*ipfslite.Peer).HasBlock(context.TODO(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !has {
+		t.Fatal("value block not cached after first read")
+	}
+}
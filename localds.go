@@ -0,0 +1,117 @@
+package antsdb
+
+import (
+	"context"
+
+	ds "github.com/ipfs/go-datastore"
+	crdt "github.com/ipfs/go-ds-crdt"
+)
+
+// localDS wraps the CRDT datastore for all the writes originating on this
+// node, so AntsDB can react to them once they are committed.
+type localDS struct {
+	*crdt.Datastore
+	a *AntsDB
+}
+
+func (l *localDS) Put(ctx context.Context, key ds.Key, value []byte) error {
+	l.a.rotating.RLock()
+	defer l.a.rotating.RUnlock()
+
+	return l.put(ctx, key, value)
+}
+
+func (l *localDS) put(ctx context.Context, key ds.Key, value []byte) error {
+	err := l.Datastore.Put(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	l.a.wrote([]ds.Key{key})
+	return nil
+}
+
+func (l *localDS) Delete(ctx context.Context, key ds.Key) error {
+	l.a.rotating.RLock()
+	defer l.a.rotating.RUnlock()
+
+	err := l.Datastore.Delete(ctx, key)
+	if err != nil {
+		return err
+	}
+	l.a.wrote([]ds.Key{key})
+	return nil
+}
+
+// Batch returns a batch which queues its operations until Commit. The CRDT
+// collects every open batch into one shared delta, so queueing there directly
+// would let another batch commit part of this one.
+func (l *localDS) Batch(ctx context.Context) (ds.Batch, error) {
+	return &localBatch{a: l.a}, nil
+}
+
+type batchOp struct {
+	key     ds.Key
+	value   []byte
+	deleted bool
+}
+
+type localBatch struct {
+	a   *AntsDB
+	ops []batchOp
+	// collects the deltas of the commit if set
+	token *Token
+}
+
+func (b *localBatch) Put(ctx context.Context, key ds.Key, value []byte) error {
+	b.ops = append(b.ops, batchOp{key: key, value: value})
+	return nil
+}
+
+func (b *localBatch) Delete(ctx context.Context, key ds.Key) error {
+	b.ops = append(b.ops, batchOp{key: key, deleted: true})
+	return nil
+}
+
+func (b *localBatch) Commit(ctx context.Context) error {
+	b.a.batching.Lock()
+	defer b.a.batching.Unlock()
+	b.a.rotating.RLock()
+	defer b.a.rotating.RUnlock()
+	if b.token != nil {
+		b.a.collectHeads(b.token)
+		defer b.a.collectHeads(nil)
+	}
+
+	batch, err := b.a.crdt.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	keys := make([]ds.Key, 0, len(b.ops))
+	for _, op := range b.ops {
+		if op.deleted {
+			err = batch.Delete(ctx, op.key)
+		} else {
+			err = batch.Put(ctx, op.key, op.value)
+		}
+		if err != nil {
+			return err
+		}
+		keys = append(keys, op.key)
+	}
+	err = batch.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	b.ops = nil
+	b.a.wrote(keys)
+	return nil
+}
+
+// wrote is called with the keys of every committed local write
+func (a *AntsDB) wrote(keys []ds.Key) {
+	a.conflicts.wrote(keys)
+	a.mirrors.wrote(keys)
+	if a.isHot(keys) {
+		signal(a.hot)
+	}
+}
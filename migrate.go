@@ -0,0 +1,134 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+var ErrMigrationInProgress = errors.New("topic migration already in progress")
+
+// MigrateTopic moves AntsDB to the pubsub topic derived from newTopic, as
+// WithChannel would. For the overlap window it listens and broadcasts on
+// both topics, then leaves the old one. It returns once the migration is
+// over, or early with ctx's error, in which case AntsDB stays on the old
+// topic.
+//
+// Nodes only hear each other while they share a topic, so every node of the
+// cluster must start its migration before the first one to start finishes:
+// all of them within one overlap window. A node left behind on the old topic
+// keeps its data but stops syncing until it migrates as well, and the
+// rebroadcast of heads then brings it up to date.
+func (a *AntsDB) MigrateTopic(ctx context.Context, newTopic string, overlap time.Duration) error {
+	b, ok := a.bcast.(*pubsubBroadcaster)
+	if !ok {
+		return ErrPubSubRequired
+	}
+	id := topicID(newTopic)
+
+	b.mtx.Lock()
+	if b.migrating != nil {
+		b.mtx.Unlock()
+		return ErrMigrationInProgress
+	}
+	topic, subs, err := a.joinTopic(id)
+	if err != nil {
+		b.mtx.Unlock()
+		return err
+	}
+	b.migrating = topic
+	b.mtx.Unlock()
+	log.Infof("Migrating to topic %s, leaving the old one in %s", id, overlap)
+
+	fwdCtx, stopForward := context.WithCancel(a.ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.forwardTopic(fwdCtx, subs)
+	}()
+
+	wait := time.NewTimer(overlap)
+	defer wait.Stop()
+	select {
+	case <-wait.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-a.ctx.Done():
+		err = a.ctx.Err()
+	}
+	stopForward()
+	wg.Wait()
+
+	if err != nil {
+		log.Warnf("Topic migration to %s aborted Err:%s", id, err.Error())
+		b.mtx.Lock()
+		b.migrating = nil
+		b.mtx.Unlock()
+		subs.Cancel()
+		a.leaveTopic(id, topic)
+		return err
+	}
+
+	var stopPeers func()
+	if a.eagerConnect {
+		stopPeers, err = b.watchPeers(topic)
+		if err != nil {
+			log.Errorf("Eager connects disabled on new topic Err:%s", err.Error())
+		}
+	}
+
+	b.mtx.Lock()
+	oldTopic, oldSubs, oldStop := b.topic, b.subs, b.stopPeers
+	b.topic, b.subs, b.stopPeers = topic, subs, stopPeers
+	b.migrating = nil
+	a.topicName = id
+	b.mtx.Unlock()
+
+	// The old topic cannot be closed before its event handler is cancelled
+	if oldStop != nil {
+		oldStop()
+	}
+	// Wakes up the receive loop, which picks up the new subscription
+	oldSubs.Cancel()
+	a.leaveTopic(oldTopic.String(), oldTopic)
+	log.Infof("Migrated to topic %s", id)
+	return nil
+}
+
+// forwardTopic hands broadcasts from a topic being migrated to to the CRDT
+func (a *AntsDB) forwardTopic(ctx context.Context, subs *pubsub.Subscription) {
+	for {
+		msg, err := subs.Next(ctx)
+		if err != nil {
+			return
+		}
+		a.observeHeads(msg.GetData(), msg.GetFrom())
+		select {
+		case a.injector.injected <- msg.GetData():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *AntsDB) leaveTopic(id string, topic *pubsub.Topic) {
+	if a.validator != nil {
+		err := a.pubsub.UnregisterTopicValidator(id)
+		if err != nil {
+			log.Debugf("Failed unregistering topic validator Err:%s", err.Error())
+		}
+	}
+	// Cancelling subscriptions and event handlers is asynchronous
+	for i := 0; i < 10; i++ {
+		err := topic.Close()
+		if err == nil {
+			return
+		}
+		log.Debugf("Failed closing pubsub topic Err:%s", err.Error())
+		time.Sleep(10 * time.Millisecond)
+	}
+}
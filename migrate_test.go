@@ -0,0 +1,80 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestMigrateTopic(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithEagerPeerConnect())
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	adb3, h3 := makeTestingHost(t)
+	defer adb3.Close()
+
+	connectHosts(t, h1, h2, h3)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, adb := range []*AntsDB{adb1, adb2} {
+		wg.Add(1)
+		go func(i int, adb *AntsDB) {
+			defer wg.Done()
+			errs[i] = adb.MigrateTopic(context.TODO(), "renamed", 3*time.Second)
+		}(i, adb)
+	}
+	<-time.After(time.Second)
+
+	// Both topics are in use during the overlap
+	err := adb1.Put(context.TODO(), "during", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb1.MigrateTopic(context.TODO(), "other", time.Second)
+	if err != ErrMigrationInProgress {
+		t.Fatal("expected ErrMigrationInProgress", err)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, adb := range []*AntsDB{adb2, adb3} {
+		_, err := adb.Get(context.TODO(), "during")
+		if err != nil {
+			t.Fatal("write during overlap not synced", err)
+		}
+	}
+
+	// Allow pubsub mesh to form on the new topic
+	<-time.After(time.Second)
+	err = adb1.Put(context.TODO(), "after", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(2 * time.Second)
+	_, err = adb2.Get(context.TODO(), "after")
+	if err != nil {
+		t.Fatal("write on new topic not synced", err)
+	}
+	_, err = adb3.Get(context.TODO(), "after")
+	if err != ds.ErrNotFound {
+		t.Fatal("node left on the old topic synced", err)
+	}
+	if adb1.topicName != topicID("renamed") {
+		t.Fatal("topic name not updated", adb1.topicName)
+	}
+	if peers := adb1.topicPeers(); peers != 1 {
+		t.Fatal("expected a single peer on the new topic", peers)
+	}
+}
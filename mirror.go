@@ -0,0 +1,191 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var mirrorRetryDelay = time.Second
+
+type mirror struct {
+	a      *AntsDB
+	dest   *AntsDB
+	mtx    sync.Mutex
+	keys   map[ds.Key]struct{}
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+type mirrors struct {
+	mtx  sync.Mutex
+	list []*mirror
+}
+
+func (m *mirrors) add(mr *mirror) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.list = append(m.list, mr)
+}
+
+func (m *mirrors) remove(mr *mirror) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for i, v := range m.list {
+		if v == mr {
+			m.list = append(m.list[:i], m.list[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *mirrors) wrote(keys []ds.Key) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, mr := range m.list {
+		mr.enqueue(keys)
+	}
+}
+
+func (mr *mirror) enqueue(keys []ds.Key) {
+	mr.mtx.Lock()
+	for _, k := range keys {
+		mr.keys[k] = struct{}{}
+	}
+	mr.mtx.Unlock()
+	signal(mr.notify)
+}
+
+func (mr *mirror) take() []ds.Key {
+	mr.mtx.Lock()
+	defer mr.mtx.Unlock()
+
+	keys := make([]ds.Key, 0, len(mr.keys))
+	for k := range mr.keys {
+		keys = append(keys, k)
+	}
+	mr.keys = make(map[ds.Key]struct{})
+	return keys
+}
+
+// forward copies the current state of key, so a key written several times
+// before it is forwarded is copied once.
+func (mr *mirror) forward(ctx context.Context, key ds.Key) error {
+	stored, err := mr.a.crdt.Get(ctx, key)
+	if err == ds.ErrNotFound {
+		return mr.dest.local.Delete(ctx, key)
+	}
+	if err != nil {
+		return err
+	}
+	// Both instances may encode values differently
+	val, err := mr.a.decodeValue(ctx, stored)
+	if err != nil {
+		return err
+	}
+	val, err = mr.dest.encodeValue(ctx, val)
+	if err != nil {
+		return err
+	}
+	return mr.dest.local.Put(ctx, key, val)
+}
+
+func (mr *mirror) forwardAll(ctx context.Context, keys []ds.Key) {
+	for i, k := range keys {
+		err := mr.forward(ctx, k)
+		if err != nil {
+			log.Errorf("Failed mirroring %s Err:%s", k, err.Error())
+			mr.enqueue(keys[i:])
+			return
+		}
+	}
+}
+
+func (mr *mirror) backfill(ctx context.Context) error {
+	results, err := mr.a.crdt.Query(ctx, query.Query{Prefix: "/", KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		mr.enqueue([]ds.Key{ds.NewKey(r.Key)})
+	}
+	return nil
+}
+
+func (mr *mirror) run(ctx context.Context) {
+	defer close(mr.done)
+	defer mr.a.mirrors.remove(mr)
+
+	for {
+		mr.forwardAll(ctx, mr.take())
+
+		var retry <-chan time.Time
+		mr.mtx.Lock()
+		if len(mr.keys) > 0 {
+			retry = time.After(mirrorRetryDelay)
+		}
+		mr.mtx.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-mr.a.ctx.Done():
+			return
+		case <-mr.stop:
+			// Hand over the tail before returning
+			mr.forwardAll(ctx, mr.take())
+			return
+		case <-mr.notify:
+		case <-retry:
+		}
+	}
+}
+
+// MirrorTo copies every key to dest and then keeps forwarding the puts and
+// deletes made on this node, until stop is called or ctx is done. Together
+// they let clients move to dest without downtime: mirror, switch the
+// clients over and stop. stop forwards what is still pending before
+// returning.
+//
+// Writes are forwarded at least once, but not necessarily in the order they
+// were made: each forward copies the current state of a key, so dest
+// converges to the state of this node, while intermediate values may be
+// skipped. Changes merged from other replicas are not forwarded, only the
+// backfill picks them up; in a multi-node cluster mirror from every node
+// taking writes. Both instances should use the same key options, as stored
+// keys are copied as they are.
+func (a *AntsDB) MirrorTo(ctx context.Context, dest *AntsDB) (func(), error) {
+	mr := &mirror{
+		a:      a,
+		dest:   dest,
+		keys:   make(map[ds.Key]struct{}),
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	// Writes made during the backfill are queued as well
+	a.mirrors.add(mr)
+	err := mr.backfill(ctx)
+	if err != nil {
+		a.mirrors.remove(mr)
+		return nil, err
+	}
+	a.spawn(func() { mr.run(ctx) })
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(mr.stop) })
+		<-mr.done
+	}, nil
+}
@@ -0,0 +1,66 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestMirrorTo(t *testing.T) {
+	src, _ := makeTestingHost(t, WithChannel("src"), WithLazyMaterialization())
+	defer src.Close()
+
+	dest, _ := makeTestingHost(t, WithChannel("dest"))
+	defer dest.Close()
+
+	for _, k := range []string{"old/1", "old/2"} {
+		err := src.Put(context.TODO(), k, []byte(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stop, err := src.MirrorTo(context.TODO(), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.Put(context.TODO(), "new/1", []byte("new/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.DeleteKey(context.TODO(), "old/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+	stop()
+
+	for k, exists := range map[string]bool{"old/1": true, "old/2": false, "new/1": true} {
+		val, err := dest.Get(context.TODO(), k)
+		if !exists {
+			if err != ds.ErrNotFound {
+				t.Fatal("deleted key mirrored", k, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(k, err)
+		}
+		if string(val) != k {
+			t.Fatal("incorrect mirrored value", k, string(val))
+		}
+	}
+
+	// Writes after stop are not forwarded
+	err = src.Put(context.TODO(), "late", []byte("late"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(100 * time.Millisecond)
+	_, err = dest.Get(context.TODO(), "late")
+	if err != ds.ErrNotFound {
+		t.Fatal("write forwarded after stop", err)
+	}
+}
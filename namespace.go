@@ -0,0 +1,97 @@
+package antsdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var ErrNamespaceOverlap = errors.New("namespace overlaps an open AntsDB instance")
+
+// openNamespaces tracks the namespaces of the AntsDB instances open in this
+// process, keyed by their storage.
+var openNamespaces = struct {
+	mtx  sync.Mutex
+	open []openNamespace
+}{}
+
+type openNamespace struct {
+	storage ds.Batching
+	ns      ds.Key
+	owner   *AntsDB
+}
+
+// WithSharedNamespace lets New open a namespace overlapping one already in
+// use on the same storage. Both instances then read and write the same keys,
+// which is only safe if that is the intent, e.g. a read-only tool inspecting
+// a running node.
+func WithSharedNamespace() Option {
+	return func(a *AntsDB) {
+		a.sharedNs = true
+	}
+}
+
+func overlaps(a, b ds.Key) bool {
+	return a.Equal(b) || a.IsAncestorOf(b) || b.IsAncestorOf(a)
+}
+
+func sameStorage(a, b ds.Batching) bool {
+	// Comparing interfaces holding uncomparable values panics
+	if !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func checkNamespace(storage ds.Batching, ns ds.Key) error {
+	for _, o := range openNamespaces.open {
+		if (storage == nil || sameStorage(o.storage, storage)) && overlaps(o.ns, ns) {
+			return fmt.Errorf("%w: %s and %s", ErrNamespaceOverlap, ns, o.ns)
+		}
+	}
+	return nil
+}
+
+// CheckNamespaceAvailable returns ErrNamespaceOverlap if ns is, contains or
+// is contained in the namespace of an AntsDB instance open in this process.
+// Unlike New, it does not know the storage ns is meant for, so it reports
+// overlaps on any storage.
+func CheckNamespaceAvailable(ns string) error {
+	openNamespaces.mtx.Lock()
+	defer openNamespaces.mtx.Unlock()
+
+	return checkNamespace(nil, ds.NewKey(ns))
+}
+
+func (a *AntsDB) claimNamespace() error {
+	openNamespaces.mtx.Lock()
+	defer openNamespaces.mtx.Unlock()
+
+	if !a.sharedNs {
+		err := checkNamespace(a.storage, a.namespace)
+		if err != nil {
+			return err
+		}
+	}
+	openNamespaces.open = append(openNamespaces.open, openNamespace{
+		storage: a.storage,
+		ns:      a.namespace,
+		owner:   a,
+	})
+	return nil
+}
+
+func (a *AntsDB) releaseNamespace() {
+	openNamespaces.mtx.Lock()
+	defer openNamespaces.mtx.Unlock()
+
+	for i, o := range openNamespaces.open {
+		if o.owner == a {
+			openNamespaces.open = append(openNamespaces.open[:i], openNamespaces.open[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	libp2p "github.com/libp2p/go-libp2p"
+	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
+)
+
+func TestNamespaceOverlap(t *testing.T) {
+	h, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	idht, err := dual.New(context.TODO(), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idht.Close()
+
+	bs := syncds.MutexWrap(datastore.NewMapDatastore())
+
+	adb1, err := New(h, idht, nil, bs, WithNamespace("apps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(h, idht, nil, bs, WithNamespace("apps/users"))
+	if !errors.Is(err, ErrNamespaceOverlap) {
+		t.Fatal("expected ErrNamespaceOverlap for child namespace", err)
+	}
+	_, err = New(h, idht, nil, bs, WithNamespace("apps"))
+	if !errors.Is(err, ErrNamespaceOverlap) {
+		t.Fatal("expected ErrNamespaceOverlap for same namespace", err)
+	}
+	if !errors.Is(CheckNamespaceAvailable("/"), ErrNamespaceOverlap) {
+		t.Fatal("expected root namespace to overlap")
+	}
+	if err := CheckNamespaceAvailable("appsx"); err != nil {
+		t.Fatal("sibling namespace reported as overlapping", err)
+	}
+
+	// Other storage does not mix data
+	adb2, err := New(h, idht, nil, syncds.MutexWrap(datastore.NewMapDatastore()), WithNamespace("apps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adb2.Close()
+
+	adb3, err := New(h, idht, nil, bs, WithNamespace("apps/users"), WithSharedNamespace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb3.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = adb1.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	adb4, err := New(h, idht, nil, bs, WithNamespace("apps/users"))
+	if err != nil {
+		t.Fatal("namespace not released on close", err)
+	}
+	defer adb4.Close()
+}
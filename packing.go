@@ -0,0 +1,137 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var (
+	packFlushInterval = 50 * time.Millisecond
+	// Stay well below the CRDT MaxBatchDeltaSize so a packed delta is
+	// never split.
+	packMaxSize = 256 * 1024
+)
+
+// WithValuePacking buffers Puts of values up to threshold bytes and commits
+// them together as a single CRDT delta, so thousands of tiny keys produce a
+// handful of DAG blocks instead of one block each. Buffered values are
+// visible to local reads immediately and are flushed every 50ms, once the
+// buffer grows too large, or on Close. Values still buffered when the process
+// crashes are lost.
+//
+// Convergence is unaffected: a packed delta is an ordinary CRDT batch and is
+// merged by every replica exactly like individual deltas would be.
+func WithValuePacking(threshold int) Option {
+	return func(a *AntsDB) {
+		a.packer = &packer{
+			threshold: threshold,
+			pending:   make(map[string][]byte),
+		}
+	}
+}
+
+type packer struct {
+	mtx       sync.Mutex
+	threshold int
+	pending   map[string][]byte
+	size      int
+}
+
+func (p *packer) get(key string) ([]byte, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	val, found := p.pending[key]
+	if !found {
+		return nil, false
+	}
+	return append([]byte(nil), val...), true
+}
+
+// drop removes a buffered value which is being overwritten or deleted by an
+// unpacked write. It waits for any in-flight flush so the older packed value
+// cannot land after the newer one.
+func (p *packer) drop(key string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if val, found := p.pending[key]; found {
+		p.size -= len(key) + len(val)
+		delete(p.pending, key)
+	}
+}
+
+func (a *AntsDB) packValue(ctx context.Context, key string, val []byte) error {
+	p := a.packer
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if old, found := p.pending[key]; found {
+		p.size -= len(key) + len(old)
+	}
+	p.pending[key] = append([]byte(nil), val...)
+	p.size += len(key) + len(val)
+
+	if p.size >= packMaxSize {
+		return a.flushPackedLocked(ctx)
+	}
+	return nil
+}
+
+func (a *AntsDB) flushPacked(ctx context.Context) error {
+	a.packer.mtx.Lock()
+	defer a.packer.mtx.Unlock()
+
+	return a.flushPackedLocked(ctx)
+}
+
+func (a *AntsDB) flushPackedLocked(ctx context.Context) error {
+	p := a.packer
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	batch, err := a.local.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for key, val := range p.pending {
+		err = batch.Put(ctx, ds.NewKey(key), val)
+		if err != nil {
+			return err
+		}
+		err = batch.Delete(ctx, ttlKey(key))
+		if err != nil {
+			return err
+		}
+	}
+	err = batch.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Packed %d values in a single delta", len(p.pending))
+	p.pending = make(map[string][]byte)
+	p.size = 0
+	return nil
+}
+
+func (a *AntsDB) flushPackedValues() {
+	ticker := time.NewTicker(packFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			err := a.flushPacked(a.ctx)
+			if err != nil {
+				log.Errorf("Failed flushing packed values Err:%s", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore/query"
+)
+
+func blockCount(t testing.TB, adb *AntsDB) int {
+	res, err := adb.storage.Query(context.TODO(), query.Query{
+		Prefix:   adb.namespace.ChildString(blocksNs).ChildString("blocks").String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+
+	count := 0
+	for r := range res.Next() {
+		if r.Error != nil {
+			t.Fatal(r.Error)
+		}
+		count++
+	}
+	return count
+}
+
+func TestValuePacking(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithValuePacking(64))
+	defer adb.Close()
+
+	before := blockCount(t, adb)
+	for i := 0; i < 100; i++ {
+		err := adb.Put(context.TODO(), fmt.Sprintf("tiny/%d", i), []byte(fmt.Sprintf("%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Buffered values are readable before the flush
+	val, err := adb.Get(context.TODO(), "tiny/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, []byte("42")) {
+		t.Fatal("incorrect value", string(val))
+	}
+
+	<-time.After(4 * packFlushInterval)
+
+	blocks := blockCount(t, adb) - before
+	if blocks == 0 || blocks > 5 {
+		t.Fatal("expected values to be packed into few blocks", blocks)
+	}
+
+	for i := 0; i < 100; i++ {
+		val, err := adb.Get(context.TODO(), fmt.Sprintf("tiny/%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(val, []byte(fmt.Sprintf("%d", i))) {
+			t.Fatal("incorrect value", string(val))
+		}
+	}
+
+	// Large values skip the buffer and replace older packed ones
+	large := bytes.Repeat([]byte("a"), 128)
+	err = adb.Put(context.TODO(), "tiny/1", []byte("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.Put(context.TODO(), "tiny/1", large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(4 * packFlushInterval)
+
+	val, err = adb.Get(context.TODO(), "tiny/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, large) {
+		t.Fatal("packed value overwrote newer value")
+	}
+}
+
+func benchmarkTinyKeys(b *testing.B, opts ...Option) {
+	adb, _ := makeTestingHost(b, opts...)
+	defer adb.Close()
+
+	before := blockCount(b, adb)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := adb.Put(context.TODO(), fmt.Sprintf("tiny/%d", i), []byte("v"))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if adb.packer != nil {
+		err := adb.flushPacked(context.TODO())
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(blockCount(b, adb)-before)/float64(b.N), "blocks/op")
+}
+
+func BenchmarkTinyKeys(b *testing.B) {
+	benchmarkTinyKeys(b)
+}
+
+func BenchmarkTinyKeysPacked(b *testing.B) {
+	benchmarkTinyKeys(b, WithValuePacking(64))
+}
@@ -0,0 +1,87 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// branches tracks the DAG branches the CRDT is walking. The CRDT opens a
+// DAG session per branch and closes it once the whole branch is merged.
+type branches struct {
+	mtx    sync.Mutex
+	active map[*walk]<-chan struct{}
+}
+
+func (b *branches) open(ctx context.Context, w *walk) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.active == nil {
+		b.active = make(map[*walk]<-chan struct{})
+	}
+	b.active[w] = ctx.Done()
+	go func() {
+		<-ctx.Done()
+		b.mtx.Lock()
+		delete(b.active, w)
+		b.mtx.Unlock()
+	}()
+}
+
+func (b *branches) snapshot() []<-chan struct{} {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	done := make([]<-chan struct{}, 0, len(b.active))
+	for _, d := range b.active {
+		done = append(done, d)
+	}
+	return done
+}
+
+// ApplyPending blocks until the CRDT has merged everything announced to
+// this node so far: the heads received from peers, including broadcasts
+// still queued by WithMaxQueuedJobs, and the whole branch below each of
+// them. It is a barrier for tests and for flows which need a received write
+// applied before going on.
+//
+// Deltas announced while ApplyPending waits may or may not be merged by the
+// time it returns. A head the CRDT fails to fetch is only retried once a
+// peer announces it again, so ApplyPending can wait until ctx is done.
+func (a *AntsDB) ApplyPending(ctx context.Context) error {
+	pending, err := a.pendingHeads(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range pending {
+		for {
+			done, err := a.isProcessed(ctx, c)
+			if err != nil {
+				return err
+			}
+			if done {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-a.ctx.Done():
+				return a.ctx.Err()
+			case <-time.After(applyPollInterval):
+			}
+		}
+	}
+	// A head is marked as merged before the deltas below it, but its
+	// branch is walked within a session opened before that.
+	for _, done := range a.branches.snapshot() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.ctx.Done():
+			return a.ctx.Err()
+		}
+	}
+	return nil
+}
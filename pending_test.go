@@ -0,0 +1,53 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestApplyPending(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+	<-time.After(time.Second)
+
+	for i := 0; i < 50; i++ {
+		err := adb1.Put(context.TODO(), fmt.Sprintf("key%d", i), []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	token, err := adb1.Token(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the announcement only
+	deadline := time.Now().Add(5 * time.Second)
+	for !adb2.writers.seen.Contains(token.Heads[0]) {
+		if time.Now().After(deadline) {
+			t.Fatal("head not announced")
+		}
+		<-time.After(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	err = adb2.ApplyPending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		_, err := adb2.Get(context.TODO(), fmt.Sprintf("key%d", i))
+		if err != nil {
+			t.Fatal("pending delta not applied", i, err)
+		}
+	}
+
+}
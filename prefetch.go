@@ -0,0 +1,119 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	dag "github.com/ipfs/go-merkledag"
+	"google.golang.org/protobuf/proto"
+)
+
+// Blocks fetched in parallel by PrefetchAll
+var prefetchConcurrency = 16
+
+// PrefetchAll fetches every block reachable from the current heads which is
+// missing locally, so the node can serve reads while offline afterwards, and
+// returns how many blocks it fetched. Besides the deltas this covers the
+// value blocks of WithLazyMaterialization, for old values as well as current
+// ones.
+//
+// The whole history is downloaded and kept: with lazy values this is every
+// value ever written, which for large or often rewritten datasets can be far
+// more bandwidth and storage than the current state needs. A GC afterwards
+// drops the value blocks no longer referenced again. Up to 16 blocks are
+// fetched at a time, each within the DAG syncer timeout; the first failure
+// or ctx being done stops the walk, leaving what was fetched so far.
+func (a *AntsDB) PrefetchAll(ctx context.Context) (int, error) {
+	heads, err := a.currentHeads(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		mtx     sync.Mutex
+		fetched int
+		next    []cid.Cid
+		failed  error
+	)
+	seen := cid.NewSet()
+	level := heads
+	for len(level) > 0 {
+		sem := make(chan struct{}, prefetchConcurrency)
+		var wg sync.WaitGroup
+		for _, c := range level {
+			if !seen.Visit(c) {
+				continue
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return fetched, ctx.Err()
+			}
+			wg.Add(1)
+			go func(c cid.Cid) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				links, missing, err := a.prefetchBlock(ctx, c)
+				mtx.Lock()
+				defer mtx.Unlock()
+				if err != nil {
+					if failed == nil {
+						failed = err
+					}
+					return
+				}
+				if missing {
+					fetched++
+				}
+				next = append(next, links...)
+			}(c)
+		}
+		wg.Wait()
+		if failed != nil {
+			return fetched, failed
+		}
+		level, next = next, nil
+	}
+	log.Infof("Prefetched %d blocks", fetched)
+	return fetched, nil
+}
+
+// prefetchBlock makes sure c is stored locally and returns the blocks it
+// references: the deltas it builds on and the lazy values it sets.
+func (a *AntsDB) prefetchBlock(ctx context.Context, c cid.Cid) ([]cid.Cid, bool, error) {
+	has, err := a.blocks.Has(ctx, c)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.dagTimeout)
+	defer cancel()
+
+	nd, err := a.syncer.Get(ctx, c)
+	if err != nil {
+		return nil, false, err
+	}
+	protoNode, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		// Raw value blocks have no links
+		return nil, !has, nil
+	}
+	refs := make([]cid.Cid, 0, len(nd.Links()))
+	for _, l := range nd.Links() {
+		refs = append(refs, l.Cid)
+	}
+	delta := &pb.Delta{}
+	err = proto.Unmarshal(protoNode.Data(), delta)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, e := range delta.GetElements() {
+		if ref, isRef := lazyRef(e.GetValue()); isRef {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, !has, nil
+}
@@ -0,0 +1,81 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPrefetchAll(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithLazyMaterialization())
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+
+	for i := 0; i < 10; i++ {
+		err := adb1.Put(context.TODO(), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("val%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	token, err := adb1.Token(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Deltas are synced, values are not
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		done, err := adb2.isProcessed(context.TODO(), token.Heads[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("deltas not synced")
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	if err := adb2.ApplyPending(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	fetched, err := adb2.PrefetchAll(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched != 10 {
+		t.Fatal("expected the value blocks to be fetched", fetched)
+	}
+	fetched, err = adb2.PrefetchAll(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched != 0 {
+		t.Fatal("expected nothing left to fetch", fetched)
+	}
+
+	// Reads are served locally
+	h2.Network().ClosePeer(h1.ID())
+	adb1.Close()
+	for i := 0; i < 10; i++ {
+		val, err := adb2.Get(context.TODO(), fmt.Sprintf("key%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != fmt.Sprintf("val%d", i) {
+			t.Fatal("incorrect value", string(val))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err = adb2.PrefetchAll(ctx)
+	if err == nil {
+		t.Fatal("expected error on cancelled context")
+	}
+}
@@ -0,0 +1,77 @@
+package antsdb
+
+import (
+	"context"
+
+	"github.com/ipfs/go-datastore/query"
+)
+
+// Progress is only reported every progressStep items to keep the callback
+// out of the hot loop.
+var progressStep = 128
+
+// OpOption configures a single call of a long running operation
+type OpOption func(*opConfig)
+
+type opConfig struct {
+	progress   func(done, total int)
+	transforms []TransformFunc
+}
+
+// WithProgress calls fn while the operation runs with the number of items
+// processed so far. Operations which count their items up front pass the
+// count as total, which costs an extra keys-only pass; otherwise total is -1.
+// fn is called a final time once the operation succeeds.
+func WithProgress(fn func(done, total int)) OpOption {
+	return func(c *opConfig) {
+		c.progress = fn
+	}
+}
+
+func newOpConfig(opts []OpOption) opConfig {
+	c := opConfig{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+type progress struct {
+	fn    func(done, total int)
+	done  int
+	total int
+}
+
+func (c opConfig) tracker(total int) *progress {
+	return &progress{fn: c.progress, total: total}
+}
+
+func (p *progress) step() {
+	p.done++
+	if p.fn != nil && p.done%progressStep == 0 {
+		p.fn(p.done, p.total)
+	}
+}
+
+func (p *progress) finish() {
+	if p.fn != nil {
+		p.fn(p.done, p.total)
+	}
+}
+
+func (a *AntsDB) countKeys(ctx context.Context, prefix string) (int, error) {
+	results, err := a.storage.Query(ctx, query.Query{Prefix: prefix, KeysOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	count := 0
+	for r := range results.Next() {
+		if r.Error != nil {
+			return 0, r.Error
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,62 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	for i := 0; i < 300; i++ {
+		err := adb.Put(context.TODO(), fmt.Sprintf("key%d", i), []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type report struct{ done, total int }
+	record := func(reports *[]report) OpOption {
+		return WithProgress(func(done, total int) {
+			*reports = append(*reports, report{done, total})
+		})
+	}
+	verify := func(reports []report) {
+		t.Helper()
+		if len(reports) < 2 {
+			t.Fatal("expected intermediate progress", reports)
+		}
+		last := reports[len(reports)-1]
+		if last.total <= 0 || last.done != last.total {
+			t.Fatal("incorrect final progress", last)
+		}
+		for i := 1; i < len(reports); i++ {
+			if reports[i].done < reports[i-1].done || reports[i].total != last.total {
+				t.Fatal("inconsistent progress", reports)
+			}
+		}
+	}
+
+	gcReports := []report{}
+	_, err := adb.GC(context.TODO(), record(&gcReports))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(gcReports)
+	blocks, err := adb.blockCount(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcReports[len(gcReports)-1].total != blocks {
+		t.Fatal("incorrect block total", gcReports[len(gcReports)-1], blocks)
+	}
+
+	cleanReports := []report{}
+	err = adb.Clean(context.TODO(), record(&cleanReports))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(cleanReports)
+}
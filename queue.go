@@ -0,0 +1,128 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+
+	crdt "github.com/ipfs/go-ds-crdt"
+)
+
+// WithMaxQueuedJobs bounds the number of received broadcasts waiting to be
+// processed by the CRDT. Once n are queued, AntsDB stops reading from the
+// pubsub subscription until the queue drains to half of n. While paused,
+// pubsub drops messages for this node once its own buffer is full, so
+// convergence waits for those heads to be rebroadcasted. This trades
+// latency during catch-up bursts for bounded memory.
+func WithMaxQueuedJobs(n int) Option {
+	return func(a *AntsDB) {
+		a.maxQueued = n
+	}
+}
+
+type broadcastQueue struct {
+	mtx    sync.Mutex
+	msgs   [][]byte
+	bytes  int
+	err    error
+	max    int
+	low    int
+	paused bool
+	ready  chan struct{}
+	resume chan struct{}
+}
+
+func newBroadcastQueue(max int) *broadcastQueue {
+	return &broadcastQueue{
+		max:    max,
+		low:    max / 2,
+		ready:  make(chan struct{}, 1),
+		resume: make(chan struct{}, 1),
+	}
+}
+
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// push returns true if the queue is full and the producer should pause
+func (q *broadcastQueue) push(data []byte) bool {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.msgs = append(q.msgs, data)
+	q.bytes += len(data)
+	signal(q.ready)
+	if len(q.msgs) >= q.max {
+		q.paused = true
+	}
+	return q.paused
+}
+
+func (q *broadcastQueue) stop(err error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.err = err
+	signal(q.ready)
+}
+
+func (q *broadcastQueue) pop(ctx context.Context) ([]byte, error) {
+	for {
+		q.mtx.Lock()
+		if len(q.msgs) > 0 {
+			data := q.msgs[0]
+			q.msgs = q.msgs[1:]
+			q.bytes -= len(data)
+			if q.paused && len(q.msgs) <= q.low {
+				q.paused = false
+				signal(q.resume)
+			}
+			if len(q.msgs) > 0 {
+				signal(q.ready)
+			}
+			q.mtx.Unlock()
+			return data, nil
+		}
+		err := q.err
+		q.mtx.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, crdt.ErrNoMoreBroadcast
+		case <-q.ready:
+		}
+	}
+}
+
+func (q *broadcastQueue) stats() (int, int) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return len(q.msgs), q.bytes
+}
+
+func (b *pubsubBroadcaster) fillQueue() {
+	for {
+		data, err := b.next()
+		if err != nil {
+			b.queue.stop(err)
+			return
+		}
+		if b.queue.push(data) {
+			log.Warnf("Broadcast queue full, pausing subscription")
+			select {
+			case <-b.a.ctx.Done():
+				b.queue.stop(crdt.ErrNoMoreBroadcast)
+				return
+			case <-b.queue.resume:
+				log.Info("Broadcast queue drained, resuming subscription")
+			}
+		}
+	}
+}
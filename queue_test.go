@@ -0,0 +1,76 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBroadcastQueue(t *testing.T) {
+	q := newBroadcastQueue(4)
+
+	for i := 0; i < 3; i++ {
+		if q.push([]byte("head")) {
+			t.Fatal("queue paused before limit")
+		}
+	}
+	if !q.push([]byte("head")) {
+		t.Fatal("queue not paused at limit")
+	}
+	if n, b := q.stats(); n != 4 || b != 16 {
+		t.Fatal("incorrect queue stats", n, b)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := q.pop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-q.resume:
+		t.Fatal("resumed above low-water mark")
+	default:
+	}
+	_, err = q.pop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-q.resume:
+	default:
+		t.Fatal("not resumed below low-water mark")
+	}
+}
+
+func TestMaxQueuedJobs(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t, WithMaxQueuedJobs(2))
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+	<-time.After(time.Second)
+
+	for i := 0; i < 20; i++ {
+		err := adb1.Put(context.TODO(), fmt.Sprintf("key/%d", i), []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Allow update to propogate
+	<-time.After(time.Second * 3)
+
+	for i := 0; i < 20; i++ {
+		_, err := adb2.Get(context.TODO(), fmt.Sprintf("key/%d", i))
+		if err != nil {
+			t.Fatal("key not synced", i, err)
+		}
+	}
+	if st := adb2.Stats(); st.QueuedBroadcasts > 2 {
+		t.Fatal("queue over limit", st)
+	}
+}
@@ -0,0 +1,185 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+)
+
+var ErrNoQuorum = errors.New("no value agreed upon by a majority of the replicas")
+
+// ReadProtocol is the libp2p protocol QuorumGet reads keys from peers on.
+// The reading peer writes a JSON encoded readRequest, and the other side
+// answers with a JSON encoded readResponse before closing the stream. Values
+// are sent as Get returns them, decrypted, so a node only answers peers its
+// WithPeerValidator allows, and nobody if it has no validator.
+const ReadProtocol protocol.ID = "/antsdb/read/1.0.0"
+
+var (
+	readTimeout = 5 * time.Second
+	// Bound on a request, well above any sensible key
+	readMaxRequest = 64 * 1024
+	// Bound on a reply, which is mostly the value
+	readMaxResponse = 16 << 20
+)
+
+type readRequest struct {
+	Key string
+}
+
+type readResponse struct {
+	Value []byte
+	Found bool
+	Error string `json:",omitempty"`
+}
+
+func (a *AntsDB) handleRead(s network.Stream) {
+	defer s.Close()
+
+	from := s.Conn().RemotePeer()
+	ctx, cancel := context.WithTimeout(a.ctx, readTimeout)
+	defer cancel()
+
+	// Only peers allowed on the topic may read
+	if a.validator == nil || !a.validator(ctx, from) {
+		_ = s.Reset()
+		return
+	}
+	_ = s.SetDeadline(time.Now().Add(readTimeout))
+	req := readRequest{}
+	err := json.NewDecoder(io.LimitReader(s, int64(readMaxRequest))).Decode(&req)
+	if err != nil {
+		log.Debugf("Invalid read request from %s Err:%s", from, err.Error())
+		_ = s.Reset()
+		return
+	}
+	res := readResponse{}
+	val, err := a.Get(ctx, req.Key)
+	switch {
+	case err == nil:
+		res.Value, res.Found = val, true
+	case err != ds.ErrNotFound:
+		res.Error = err.Error()
+	}
+	err = json.NewEncoder(s).Encode(res)
+	if err != nil {
+		log.Debugf("Failed answering read from %s Err:%s", from, err.Error())
+		_ = s.Reset()
+	}
+}
+
+func (a *AntsDB) readFrom(ctx context.Context, p peer.ID, key string) (readResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	s, err := a.host.NewStream(ctx, p, ReadProtocol)
+	if err != nil {
+		return readResponse{}, err
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+	err = json.NewEncoder(s).Encode(readRequest{Key: key})
+	if err != nil {
+		_ = s.Reset()
+		return readResponse{}, err
+	}
+	res := readResponse{}
+	err = json.NewDecoder(io.LimitReader(s, int64(readMaxResponse))).Decode(&res)
+	if err != nil {
+		_ = s.Reset()
+		return readResponse{}, err
+	}
+	if res.Error != "" {
+		return readResponse{}, errors.New(res.Error)
+	}
+	return res, nil
+}
+
+// QuorumGet reads key on this node and on n peers of the topic picked at
+// random, over ReadProtocol, and returns the value a strict majority of
+// those n+1 replicas agree on. A majority agreeing the key does not exist
+// gives ds.ErrNotFound. Without a majority, including when fewer peers are
+// available or answer within 5 seconds, it fails with ErrNoQuorum.
+//
+// This is best effort: replicas are only compared at the time they answer,
+// and a majority can just as well agree on a value a newer write elsewhere
+// is still replacing. Every call costs a stream and a full value transfer
+// per peer, so keep it for the few reads where one lagging node is a real
+// risk. Peers only answer if they have a WithPeerValidator allowing this
+// node.
+func (a *AntsDB) QuorumGet(ctx context.Context, key string, n int) ([]byte, error) {
+	b, ok := a.bcast.(*pubsubBroadcaster)
+	if !ok {
+		return nil, ErrPubSubRequired
+	}
+	topic, _ := b.current()
+	peers := topic.ListPeers()
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > n {
+		peers = peers[:n]
+	}
+
+	var (
+		mtx     sync.Mutex
+		wg      sync.WaitGroup
+		answers []readResponse
+	)
+	val, err := a.Get(ctx, key)
+	switch {
+	case err == nil:
+		answers = append(answers, readResponse{Value: val, Found: true})
+	case err == ds.ErrNotFound:
+		answers = append(answers, readResponse{})
+	default:
+		log.Debugf("Local read of %s left out of the quorum Err:%s", key, err.Error())
+	}
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+
+			res, err := a.readFrom(ctx, p, key)
+			if err != nil {
+				log.Debugf("No quorum answer from %s Err:%s", p, err.Error())
+				return
+			}
+			mtx.Lock()
+			answers = append(answers, res)
+			mtx.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	for _, candidate := range answers {
+		votes := 0
+		for _, other := range answers {
+			if other.Found == candidate.Found && bytes.Equal(other.Value, candidate.Value) {
+				votes++
+			}
+		}
+		if votes <= (n+1)/2 {
+			continue
+		}
+		if !candidate.Found {
+			return nil, ds.ErrNotFound
+		}
+		return candidate.Value, nil
+	}
+	return nil, ErrNoQuorum
+}
@@ -0,0 +1,86 @@
+package antsdb
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func answering(val string) network.StreamHandler {
+	return func(s network.Stream) {
+		defer s.Close()
+		_ = json.NewDecoder(s).Decode(&readRequest{})
+		_ = json.NewEncoder(s).Encode(readResponse{Value: []byte(val), Found: true})
+	}
+}
+
+func TestQuorumGet(t *testing.T) {
+	allow := WithPeerValidator(func(context.Context, peer.ID) bool { return true })
+
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t, allow)
+	defer adb2.Close()
+
+	adb3, h3 := makeTestingHost(t, allow)
+	defer adb3.Close()
+
+	connectHosts(t, h1, h2, h3)
+	<-time.After(time.Second)
+
+	err := adb2.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(time.Second)
+
+	val, err := adb1.QuorumGet(context.TODO(), "key", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value", string(val))
+	}
+	_, err = adb1.QuorumGet(context.TODO(), "missing", 2)
+	if err != ds.ErrNotFound {
+		t.Fatal("expected ErrNotFound", err)
+	}
+
+	// Replicas disagreeing
+	h2.SetStreamHandler(ReadProtocol, answering("x"))
+	h3.SetStreamHandler(ReadProtocol, answering("y"))
+	_, err = adb1.QuorumGet(context.TODO(), "key", 2)
+	if err != ErrNoQuorum {
+		t.Fatal("expected ErrNoQuorum", err)
+	}
+
+	// The majority wins over the local value
+	h3.SetStreamHandler(ReadProtocol, answering("x"))
+	val, err = adb1.QuorumGet(context.TODO(), "key", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "x" {
+		t.Fatal("incorrect value", string(val))
+	}
+
+	// Not enough peers for a majority
+	h3.RemoveStreamHandler(ReadProtocol)
+	h2.RemoveStreamHandler(ReadProtocol)
+	_, err = adb1.QuorumGet(context.TODO(), "key", 2)
+	if err != ErrNoQuorum {
+		t.Fatal("expected ErrNoQuorum", err)
+	}
+
+	// Nodes without a validator do not serve reads
+	_, err = adb2.readFrom(context.TODO(), h1.ID(), "key")
+	if err == nil {
+		t.Fatal("node without validator answered a read")
+	}
+}
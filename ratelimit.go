@@ -0,0 +1,141 @@
+package antsdb
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	metrics "github.com/ipfs/go-metrics-interface"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithBroadcastRateLimit publishes at most msgsPerSec broadcasts per second,
+// allowing bursts of up to msgsPerSec. Broadcasts over the limit wait and
+// are coalesced: only the heads of the latest one go out in the next
+// message, as every delta written locally builds on the heads before it, so
+// peers fetch the deltas of the skipped broadcasts from there.
+// Heads waiting to be published are only seen by peers on the next slot, so
+// a low limit delays convergence during write bursts.
+func WithBroadcastRateLimit(msgsPerSec int) Option {
+	return func(a *AntsDB) {
+		a.bcastRate = msgsPerSec
+	}
+}
+
+type rateLimiter struct {
+	// Updated atomically, see AntsDB
+	coalesced uint64
+	mtx       sync.Mutex
+	rate      float64
+	tokens    float64
+	last      time.Time
+	// heads of the latest broadcast waiting for a slot
+	pending []cid.Cid
+	notify  chan struct{}
+	gauge   metrics.Gauge
+}
+
+func newRateLimiter(a *AntsDB) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(a.bcastRate),
+		tokens: float64(a.bcastRate),
+		last:   time.Now(),
+		notify: make(chan struct{}, 1),
+		gauge: metrics.NewCtx(
+			metrics.CtxSubScope(a.ctx, "antsdb"),
+			"outbound_queued_heads",
+			"Heads waiting for the broadcast rate limit",
+		).Gauge(),
+	}
+}
+
+// takeLocked returns true if a message can be published now
+func (r *rateLimiter) takeLocked() bool {
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.rate {
+		r.tokens = r.rate
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// allow returns true if data can be published straight away, otherwise its
+// heads are queued.
+func (r *rateLimiter) allow(data []byte) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if len(r.pending) == 0 && r.takeLocked() {
+		return true
+	}
+	waiting := len(r.pending) > 0
+	// Supersedes the heads waiting so far
+	r.pending = decodeHeads(data)
+	if waiting {
+		atomic.AddUint64(&r.coalesced, 1)
+	}
+	r.gauge.Set(float64(len(r.pending)))
+	signal(r.notify)
+	return false
+}
+
+// next returns the queued heads once a slot is free
+func (r *rateLimiter) next() ([]byte, time.Duration, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if len(r.pending) == 0 {
+		return nil, 0, nil
+	}
+	if !r.takeLocked() {
+		return nil, time.Duration((1 - r.tokens) / r.rate * float64(time.Second)), nil
+	}
+	bcast := &pb.CRDTBroadcast{}
+	for _, c := range r.pending {
+		bcast.Heads = append(bcast.Heads, &pb.Head{Cid: c.Bytes()})
+	}
+	r.pending = nil
+	r.gauge.Set(0)
+	data, err := proto.Marshal(bcast)
+	return data, 0, err
+}
+
+func (r *rateLimiter) stats() (int, uint64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return len(r.pending), atomic.LoadUint64(&r.coalesced)
+}
+
+func (b *pubsubBroadcaster) publishQueued() {
+	for {
+		data, wait, err := b.limiter.next()
+		switch {
+		case err != nil:
+			log.Errorf("Failed encoding queued heads Err:%s", err.Error())
+		case data != nil:
+			err = b.publish(data)
+			if err != nil {
+				log.Errorf("Failed publishing queued heads Err:%s", err.Error())
+			}
+			continue
+		}
+		var slot <-chan time.Time
+		if wait > 0 {
+			slot = time.After(wait)
+		}
+		select {
+		case <-b.a.ctx.Done():
+			return
+		case <-b.limiter.notify:
+		case <-slot:
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBroadcastRateLimit(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithBroadcastRateLimit(2))
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+	<-time.After(time.Second)
+
+	for i := 0; i < 20; i++ {
+		err := adb1.Put(context.TODO(), fmt.Sprintf("burst/%d", i), []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := adb1.Stats()
+	if st.QueuedOutbound == 0 || st.CoalescedBroadcasts == 0 {
+		t.Fatal("burst not queued", st)
+	}
+	// Only the latest head waits, the others are its ancestors
+	if st.QueuedOutbound != 1 {
+		t.Fatal("stale heads queued", st)
+	}
+
+	<-time.After(3 * time.Second)
+	if st := adb1.Stats(); st.QueuedOutbound != 0 {
+		t.Fatal("queued heads not published", st)
+	}
+	for i := 0; i < 20; i++ {
+		_, err := adb2.Get(context.TODO(), fmt.Sprintf("burst/%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
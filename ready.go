@@ -0,0 +1,123 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+)
+
+var (
+	readyPollInterval = 100 * time.Millisecond
+	// go-ds-crdt keeps processed blocks under this namespace
+	processedBlocksNs = "b"
+)
+
+type ReadyOptions struct {
+	// MinPeers is the number of peers which need to be subscribed to the
+	// channel.
+	MinPeers int
+	// DAGSyncer waits for the DAG syncer and CRDT datastore to be set up.
+	DAGSyncer bool
+	// InitialSync waits for a broadcast from a remote peer and for every
+	// head announced so far to be processed. It never completes on a node
+	// without peers.
+	InitialSync bool
+	// Storage checks the underlying datastore answers queries.
+	Storage bool
+}
+
+func (a *AntsDB) LastRemoteUpdate() time.Time {
+	last := atomic.LoadInt64(&a.lastRemote)
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+func (a *AntsDB) isProcessed(ctx context.Context, c cid.Cid) (bool, error) {
+	return a.storage.Has(
+		ctx,
+		a.namespace.ChildString(processedBlocksNs).Child(dshelp.MultihashToDsKey(c.Hash())),
+	)
+}
+
+// pendingHeads returns the heads announced to this node which the CRDT is
+// yet to process.
+func (a *AntsDB) pendingHeads(ctx context.Context) ([]cid.Cid, error) {
+	pending := []cid.Cid{}
+	for _, c := range a.writers.heads() {
+		done, err := a.isProcessed(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if !done {
+			pending = append(pending, c)
+		}
+	}
+	return pending, nil
+}
+
+func (a *AntsDB) topicPeers() int {
+	b, ok := a.bcast.(*pubsubBroadcaster)
+	if !ok {
+		return 0
+	}
+	topic, _ := b.current()
+	return len(topic.ListPeers())
+}
+
+// unmetCondition returns a description of the first condition which does not
+// hold yet or an empty string if AntsDB is ready.
+func (a *AntsDB) unmetCondition(ctx context.Context, opts ReadyOptions) string {
+	if opts.Storage {
+		_, err := a.storage.Has(ctx, ds.NewKey("/"))
+		if err != nil {
+			return fmt.Sprintf("storage not responding (%s)", err.Error())
+		}
+	}
+	if opts.DAGSyncer && (a.syncer == nil || a.crdt == nil) {
+		return "DAG syncer not initialized"
+	}
+	if peers := a.topicPeers(); peers < opts.MinPeers {
+		return fmt.Sprintf("connected to %d of %d peers", peers, opts.MinPeers)
+	}
+	if opts.InitialSync {
+		if a.LastRemoteUpdate().IsZero() {
+			return "initial sync not started, no updates from peers"
+		}
+		pending, err := a.pendingHeads(ctx)
+		if err != nil {
+			return fmt.Sprintf("initial sync status unknown (%s)", err.Error())
+		}
+		if len(pending) > 0 {
+			return fmt.Sprintf("initial sync in progress, %d heads pending", len(pending))
+		}
+	}
+	return ""
+}
+
+// Ready blocks till all the conditions in opts hold. If ctx expires first,
+// the error describes the condition which was not met.
+func (a *AntsDB) Ready(ctx context.Context, opts ReadyOptions) error {
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		unmet := a.unmetCondition(ctx, opts)
+		if unmet == "" {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("antsdb not ready: %s: %w", unmet, ctx.Err())
+		case <-a.ctx.Done():
+			return fmt.Errorf("antsdb not ready: %s: %w", unmet, a.ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := adb2.Ready(ctx, ReadyOptions{MinPeers: 1, Storage: true, DAGSyncer: true})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "peers") {
+		t.Fatal("expected peers condition to fail", err)
+	}
+
+	err = adb1.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectHosts(t, h1, h2)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = adb2.Ready(ctx, ReadyOptions{
+		MinPeers:    1,
+		Storage:     true,
+		DAGSyncer:   true,
+		InitialSync: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = adb2.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal("key not synced when ready", err)
+	}
+}
@@ -0,0 +1,47 @@
+package antsdb
+
+import (
+	"errors"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var ErrReservedKey = errors.New("key is under a prefix reserved by AntsDB")
+
+// ReservedPrefixes lists the key prefixes AntsDB writes to with the options
+// it was created with. Keys under them are rejected by Put, PutWithTTL,
+// DeleteKey and Tx with ErrReservedKey. Writes of TTLs and pins are not
+// reported by WatchWhere, Events or the change log, while hashed keys hold
+// user values and are, see WithKeyHashing. TTLs and pins need no option, so
+// their prefixes are always reserved.
+func (a *AntsDB) ReservedPrefixes() []string {
+	prefixes := []string{ttlNs, pinsNs}
+	if a.hashKeys {
+		prefixes = append(prefixes, hashedKeysNs)
+	}
+	return prefixes
+}
+
+func (a *AntsDB) isReserved(key string) bool {
+	k := ds.NewKey(key)
+	for _, p := range a.ReservedPrefixes() {
+		pk := ds.NewKey(p)
+		if k.Equal(pk) || k.IsDescendantOf(pk) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMetadata reports whether key holds AntsDB's own records. Hashed keys are
+// reserved as well, but hold user values.
+func (a *AntsDB) isMetadata(key string) bool {
+	return a.isReserved(key) && !ds.NewKey(key).IsDescendantOf(ds.NewKey(hashedKeysNs))
+}
+
+func (a *AntsDB) checkReserved(key string) error {
+	if a.isReserved(key) {
+		return ErrReservedKey
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package antsdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReservedPrefixes(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithMaxKeyLength(32), WithKeyHashing())
+	defer adb.Close()
+
+	prefixes := adb.ReservedPrefixes()
+	if len(prefixes) != 3 || prefixes[2] != hashedKeysNs {
+		t.Fatal("incorrect reserved prefixes", prefixes)
+	}
+	for _, k := range []string{"_ttl/key", "/_pins/key", "/_h/abc"} {
+		if err := adb.Put(context.TODO(), k, []byte("val")); err != ErrReservedKey {
+			t.Fatal("expected reserved key error on Put", k, err)
+		}
+		if err := adb.PutWithTTL(context.TODO(), k, []byte("val"), time.Minute); err != ErrReservedKey {
+			t.Fatal("expected reserved key error on PutWithTTL", k, err)
+		}
+		if err := adb.DeleteKey(context.TODO(), k); err != ErrReservedKey {
+			t.Fatal("expected reserved key error on DeleteKey", k, err)
+		}
+		if err := adb.Begin().Put(k, []byte("val")).Commit(context.TODO()); err != ErrReservedKey {
+			t.Fatal("expected reserved key error on Tx", k, err)
+		}
+	}
+	if err := adb.Put(context.TODO(), "_ttlx/key", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReservedNotWatched(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithMaxKeyLength(32), WithKeyHashing())
+	defer adb.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := adb.WatchWhere(ctx, func(key string, val []byte) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("k", 64)
+	err = adb.PutWithTTL(context.TODO(), long, []byte("val"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The hashed key is reported under the original one, the TTL not at all
+	for _, want := range []string{"/" + long, "/key"} {
+		select {
+		case ev := <-events:
+			if len(ev.Batch) != 1 || ev.Batch[0].Key != want || string(ev.Batch[0].Value) != "val" {
+				t.Fatal("expected put of", want, ev.Batch)
+			}
+		case <-ctx.Done():
+			t.Fatal("put not reported", want)
+		}
+	}
+}
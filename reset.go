@@ -0,0 +1,64 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var ErrResetNotConfirmed = errors.New("reset deletes every key and needs confirmation")
+
+// Reset deletes every key of the CRDT, including TTLs and pins, in a single
+// batch. Unlike deleting keys one at a time, this produces one delta
+// carrying all the tombstones, split only where it would exceed the CRDT's
+// maximum delta size. Reset does nothing unless confirm is set.
+//
+// The CRDT has no notion of truncation, so Reset is only a truncate when all
+// peers accept it: writes this node had not seen yet when the reset was
+// issued survive it, and so does the DAG history. Stop writers, or let
+// them sync, before resetting.
+func (a *AntsDB) Reset(ctx context.Context, confirm bool) error {
+	if !confirm {
+		return ErrResetNotConfirmed
+	}
+	if a.packer != nil {
+		a.packer.mtx.Lock()
+		a.packer.pending = make(map[string][]byte)
+		a.packer.size = 0
+		a.packer.mtx.Unlock()
+	}
+
+	results, err := a.crdt.Query(ctx, query.Query{KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	// Collect first, the batch may commit while results are still read
+	keys := []ds.Key{}
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return r.Error
+		}
+		keys = append(keys, ds.NewKey(r.Key))
+	}
+	results.Close()
+
+	batch, err := a.local.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		err = batch.Delete(ctx, k)
+		if err != nil {
+			return err
+		}
+	}
+	err = batch.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	log.Infof("Reset removed %d keys", len(keys))
+	return nil
+}
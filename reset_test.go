@@ -0,0 +1,65 @@
+package antsdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestReset(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	for i := 0; i < 50; i++ {
+		err := adb1.Put(context.TODO(), fmt.Sprintf("key%d", i), []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := adb1.PutWithTTL(context.TODO(), "ttl", []byte("val"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(time.Second * 2)
+
+	err = adb1.Reset(context.TODO(), false)
+	if err != ErrResetNotConfirmed {
+		t.Fatal("expected ErrResetNotConfirmed", err)
+	}
+	if _, err := adb1.Get(context.TODO(), "key0"); err != nil {
+		t.Fatal("unconfirmed reset removed data", err)
+	}
+
+	before := blockCount(t, adb1)
+	err = adb1.Reset(context.TODO(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks := blockCount(t, adb1) - before; blocks != 1 {
+		t.Fatal("expected a single delta", blocks)
+	}
+	<-time.After(time.Second * 2)
+
+	for _, adb := range []*AntsDB{adb1, adb2} {
+		for i := 0; i < 50; i++ {
+			_, err := adb.Get(context.TODO(), fmt.Sprintf("key%d", i))
+			if err != ds.ErrNotFound {
+				t.Fatal("key not reset", i, err)
+			}
+		}
+		_, err := adb.Get(context.TODO(), "ttl")
+		if err != ds.ErrNotFound {
+			t.Fatal("ttl key not reset", err)
+		}
+	}
+}
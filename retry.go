@@ -0,0 +1,168 @@
+package antsdb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// Retries of a failing callback wait at most this long
+	maxSubBackoff = time.Hour
+
+	ErrConflictingSubscribers = errors.New("WithSubscriber and WithRetryableSubscriber are mutually exclusive")
+)
+
+// RetryableSubscriber is a Subscriber which can report failures. Failed
+// callbacks are retried as configured with WithSubscriberRetry.
+type RetryableSubscriber interface {
+	Put(string) error
+	Delete(string) error
+}
+
+// WithRetryableSubscriber is used in place of WithSubscriber, New fails
+// with ErrConflictingSubscribers if both are given. Retries run in
+// the background, so a key which keeps failing does not hold back the
+// notifications for other keys, and a retried callback can be delivered
+// after later ones for the same key.
+func WithRetryableSubscriber(s RetryableSubscriber) Option {
+	return func(a *AntsDB) {
+		a.retrySub = s
+	}
+}
+
+// WithSubscriberRetry retries a failed callback up to max times, doubling the
+// wait after each attempt starting at backoff, up to an hour. Without it failures are handed
+// to the dead-letter hook straight away.
+func WithSubscriberRetry(max int, backoff time.Duration) Option {
+	return func(a *AntsDB) {
+		a.subRetries = max
+		a.subBackoff = backoff
+	}
+}
+
+// WithDeadLetterHook is called with the last error for callbacks which are
+// still failing after all the retries.
+func WithDeadLetterHook(hook func(key string, deleted bool, err error)) Option {
+	return func(a *AntsDB) {
+		a.deadLetter = hook
+	}
+}
+
+type retryJob struct {
+	key     string
+	deleted bool
+	attempt int
+	due     time.Time
+}
+
+// retrier adapts a RetryableSubscriber to the CRDT hooks
+type retrier struct {
+	a      *AntsDB
+	sub    RetryableSubscriber
+	mtx    sync.Mutex
+	jobs   []*retryJob
+	notify chan struct{}
+}
+
+func newRetrier(a *AntsDB) *retrier {
+	return &retrier{
+		a:      a,
+		sub:    a.retrySub,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (r *retrier) Put(key string) {
+	r.call(&retryJob{key: key})
+}
+
+func (r *retrier) Delete(key string) {
+	r.call(&retryJob{key: key, deleted: true})
+}
+
+func (r *retrier) call(job *retryJob) {
+	var err error
+	if job.deleted {
+		err = r.sub.Delete(job.key)
+	} else {
+		err = r.sub.Put(job.key)
+	}
+	if err == nil {
+		return
+	}
+	job.attempt++
+	if job.attempt > r.a.subRetries {
+		log.Errorf("Subscriber failed on %s after %d attempts Err:%s", job.key, job.attempt, err.Error())
+		if r.a.deadLetter != nil {
+			r.a.deadLetter(job.key, job.deleted, err)
+		}
+		return
+	}
+	job.due = time.Now().Add(r.backoff(job.attempt))
+
+	r.mtx.Lock()
+	r.jobs = append(r.jobs, job)
+	r.mtx.Unlock()
+	signal(r.notify)
+}
+
+// backoff doubles the wait for every attempt, stopping at maxSubBackoff
+// before it can overflow
+func (r *retrier) backoff(attempt int) time.Duration {
+	d := r.a.subBackoff
+	for i := 1; i < attempt && d > 0 && d < maxSubBackoff; i++ {
+		d *= 2
+	}
+	if d > maxSubBackoff {
+		d = maxSubBackoff
+	}
+	return d
+}
+
+// next removes the jobs which are due and returns when the following one is
+func (r *retrier) next(now time.Time) ([]*retryJob, time.Time) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var (
+		due     []*retryJob
+		waiting []*retryJob
+		wakeup  time.Time
+	)
+	for _, j := range r.jobs {
+		if !j.due.After(now) {
+			due = append(due, j)
+			continue
+		}
+		waiting = append(waiting, j)
+		if wakeup.IsZero() || j.due.Before(wakeup) {
+			wakeup = j.due
+		}
+	}
+	r.jobs = waiting
+	return due, wakeup
+}
+
+func (r *retrier) run() {
+	for {
+		due, wakeup := r.next(time.Now())
+		for _, j := range due {
+			r.call(j)
+		}
+		if len(due) > 0 {
+			// Failed jobs may have been queued again
+			continue
+		}
+		var timer <-chan time.Time
+		if !wakeup.IsZero() {
+			timer = time.After(time.Until(wakeup))
+		}
+		select {
+		case <-r.a.ctx.Done():
+			return
+		case <-r.notify:
+		case <-timer:
+		}
+	}
+}
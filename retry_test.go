@@ -0,0 +1,100 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type flakySubscriber struct {
+	mtx      sync.Mutex
+	failures map[string]int
+	attempts map[string]int
+	done     map[string]bool
+}
+
+func (f *flakySubscriber) Put(key string) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.attempts[key]++
+	if f.attempts[key] <= f.failures[key] {
+		return errors.New("downstream unavailable")
+	}
+	f.done[key] = true
+	return nil
+}
+
+func (f *flakySubscriber) Delete(key string) error { return f.Put(key) }
+
+func TestSubscriberRetry(t *testing.T) {
+	sub := &flakySubscriber{
+		failures: map[string]int{"/flaky": 2, "/broken": 10},
+		attempts: make(map[string]int),
+		done:     make(map[string]bool),
+	}
+	deadLetters := make(chan string, 10)
+
+	adb, _ := makeTestingHost(t,
+		WithRetryableSubscriber(sub),
+		WithSubscriberRetry(3, 10*time.Millisecond),
+		WithDeadLetterHook(func(key string, deleted bool, err error) {
+			deadLetters <- key
+		}),
+	)
+	defer adb.Close()
+
+	for _, k := range []string{"ok", "flaky", "broken"} {
+		err := adb.Put(context.TODO(), k, []byte(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case key := <-deadLetters:
+		if key != "/broken" {
+			t.Fatal("unexpected dead letter", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead-letter hook not called")
+	}
+
+	sub.mtx.Lock()
+	defer sub.mtx.Unlock()
+	if !sub.done["/ok"] || !sub.done["/flaky"] || sub.done["/broken"] {
+		t.Fatal("incorrect deliveries", sub.done)
+	}
+	if sub.attempts["/flaky"] != 3 || sub.attempts["/broken"] != 4 {
+		t.Fatal("incorrect attempts", sub.attempts)
+	}
+}
+
+type nopSubscriber struct{}
+
+func (nopSubscriber) Put(string)    {}
+func (nopSubscriber) Delete(string) {}
+
+func TestConflictingSubscribers(t *testing.T) {
+	sub := &flakySubscriber{}
+	_, err := New(nil, nil, nil, nil, WithSubscriber(nopSubscriber{}), WithRetryableSubscriber(sub))
+	if err != ErrConflictingSubscribers {
+		t.Fatal("expected ErrConflictingSubscribers", err)
+	}
+}
+
+func TestSubscriberRetryBackoff(t *testing.T) {
+	r := &retrier{a: &AntsDB{subBackoff: time.Second}}
+	for attempt, want := range map[int]time.Duration{
+		1:    time.Second,
+		3:    4 * time.Second,
+		100:  maxSubBackoff,
+		1000: maxSubBackoff,
+	} {
+		if got := r.backoff(attempt); got != want {
+			t.Fatal("incorrect backoff for attempt", attempt, got)
+		}
+	}
+}
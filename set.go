@@ -0,0 +1,84 @@
+package antsdb
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var ErrInvalidMember = errors.New("set member cannot be empty, \".\" or \"..\"")
+
+// Set is an observed-remove set of strings stored under a key prefix. A
+// member is a key of its own, and the CRDT deletes only the versions of a
+// key it has seen: a Remove races with a concurrent Add on another replica
+// and the Add wins, instead of whichever write happens to be last as with a
+// single value holding all the members.
+//
+// Removed members still cost storage. Every Remove adds a tombstone for each
+// Add it observed, and tombstones are kept by all replicas for good, so a
+// member added and removed over and over grows the DAG and the tombstone
+// set with every cycle even though the set itself stays small.
+type Set struct {
+	a      *AntsDB
+	prefix ds.Key
+}
+
+// Set returns the set stored under the name prefix. Other keys must not be
+// written under it, or they show up as members.
+func (a *AntsDB) Set(name string) *Set {
+	return &Set{a: a, prefix: ds.NewKey(name)}
+}
+
+func (s *Set) key(member string) (string, error) {
+	if member == "" || member == "." || member == ".." {
+		return "", ErrInvalidMember
+	}
+	return s.prefix.ChildString(url.PathEscape(member)).String(), nil
+}
+
+func (s *Set) Add(ctx context.Context, member string) error {
+	key, err := s.key(member)
+	if err != nil {
+		return err
+	}
+	// Deltas are content addressed: without a tag of its own, an Add could
+	// produce the very delta another replica observed and removed.
+	tag := make([]byte, 16)
+	_, err = rand.Read(tag)
+	if err != nil {
+		return err
+	}
+	return s.a.Put(ctx, key, tag)
+}
+
+// Remove removes member as far as this replica has seen it added.
+func (s *Set) Remove(ctx context.Context, member string) error {
+	key, err := s.key(member)
+	if err != nil {
+		return err
+	}
+	return s.a.DeleteKey(ctx, key)
+}
+
+// Members returns the members of the set, sorted.
+func (s *Set) Members(ctx context.Context) ([]string, error) {
+	members := []string{}
+	err := s.a.scan(ctx, s.prefix.String(), nil, opConfig{}, func(key string, _ []byte) (bool, error) {
+		member, err := url.PathUnescape(strings.TrimPrefix(key, s.prefix.String()+"/"))
+		if err != nil {
+			return false, err
+		}
+		members = append(members, member)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(members)
+	return members, nil
+}
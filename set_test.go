@@ -0,0 +1,76 @@
+package antsdb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetConvergence(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithRebroadcastDuration(200*time.Millisecond))
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t, WithRebroadcastDuration(200*time.Millisecond))
+	defer adb2.Close()
+
+	s1, s2 := adb1.Set("/tags"), adb2.Set("/tags")
+
+	// Concurrent writes while the replicas cannot talk
+	for _, m := range []string{"x", "y/z", "w"} {
+		if err := s1.Add(context.TODO(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s2.Add(context.TODO(), "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.Remove(context.TODO(), "x"); err != nil {
+		t.Fatal(err)
+	}
+	// Not seen by this replica yet
+	if err := s2.Remove(context.TODO(), "w"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.Add(context.TODO(), ""); err != ErrInvalidMember {
+		t.Fatal("expected ErrInvalidMember", err)
+	}
+
+	members, err := s1.Members(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(members, []string{"w", "y/z"}) {
+		t.Fatal("incorrect members before sync", members)
+	}
+
+	connectHosts(t, h1, h2)
+	<-time.After(3 * time.Second)
+
+	// The add unseen by the remove wins
+	expected := []string{"w", "x", "y/z"}
+	for _, s := range []*Set{s1, s2} {
+		members, err := s.Members(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(members, expected) {
+			t.Fatal("set did not converge", members)
+		}
+	}
+
+	// A remove which observed every add deletes the member everywhere
+	if err := s2.Remove(context.TODO(), "x"); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(time.Second)
+	for _, s := range []*Set{s1, s2} {
+		members, err := s.Members(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(members, []string{"w", "y/z"}) {
+			t.Fatal("remove did not converge", members)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	store "github.com/plexsysio/gkvstore"
+)
+
+var ErrStale = errors.New("no update from peers within the staleness threshold")
+
+// StaleAction is what reads do once the local data is stale
+type StaleAction int
+
+const (
+	// StaleWarn logs a warning when the data becomes stale and keeps
+	// serving reads. Stale tells whether a read may be outdated.
+	StaleWarn StaleAction = iota
+	// StaleFail makes reads return ErrStale while the data is stale.
+	StaleFail
+)
+
+// WithStalenessThreshold considers the local data stale once no update
+// from peers arrived for d, as reported by LastRemoteUpdate, or since
+// startup if there was none yet. Peers rebroadcast their heads regularly,
+// so d should be a few rebroadcast intervals at least.
+//
+// The action applies to Get, GetConsistent and to Read and List of the
+// Store. Entering and leaving the stale state is logged either way, and
+// reported by FullStatus next to the sync state.
+func WithStalenessThreshold(d time.Duration, action StaleAction) Option {
+	return func(a *AntsDB) {
+		a.staleAfter = d
+		a.staleAction = action
+	}
+}
+
+// Stale reports whether the local data is stale. Without
+// WithStalenessThreshold it is always false.
+func (a *AntsDB) Stale() bool {
+	if a.staleAfter <= 0 {
+		return false
+	}
+	last := a.LastRemoteUpdate()
+	if last.IsZero() {
+		last = a.started
+	}
+	stale := time.Since(last) > a.staleAfter
+
+	if stale && atomic.CompareAndSwapInt32(&a.stale, 0, 1) {
+		log.Warnf("Local data stale, last update from peers %s ago", time.Since(last).Round(time.Second))
+	}
+	if !stale && atomic.CompareAndSwapInt32(&a.stale, 1, 0) {
+		log.Info("Local data up to date again")
+	}
+	return stale
+}
+
+func (a *AntsDB) checkStale() error {
+	if a.Stale() && a.staleAction == StaleFail {
+		return ErrStale
+	}
+	return nil
+}
+
+// watchStaleness logs the transitions even while nothing is read
+func (a *AntsDB) watchStaleness() {
+	ticker := time.NewTicker(syncPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.Stale()
+		}
+	}
+}
+
+// staleStore checks for staleness ahead of the Store, which reports every
+// failed read as a missing record.
+type staleStore struct {
+	store.Store
+	a *AntsDB
+}
+
+func (s *staleStore) Read(ctx context.Context, i store.Item) error {
+	if err := s.a.checkStale(); err != nil {
+		return err
+	}
+	return s.Store.Read(ctx, i)
+}
+
+func (s *staleStore) List(
+	ctx context.Context,
+	factory store.Factory,
+	opts store.ListOpt,
+) (<-chan *store.Result, error) {
+	if err := s.a.checkStale(); err != nil {
+		return nil, err
+	}
+	return s.Store.List(ctx, factory, opts)
+}
@@ -0,0 +1,83 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStalenessThreshold(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithStalenessThreshold(500*time.Millisecond, StaleFail))
+	defer adb1.Close()
+
+	d := &dbObj{Namespace: "antsObj", Id: "obj", FileName: "MyTestFile.txt"}
+	err := adb1.Create(context.TODO(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb1.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb1.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No peers to hear from
+	<-time.After(time.Second)
+	if !adb1.Stale() {
+		t.Fatal("expected stale data")
+	}
+	_, err = adb1.Get(context.TODO(), "key")
+	if err != ErrStale {
+		t.Fatal("expected ErrStale", err)
+	}
+	err = adb1.Read(context.TODO(), &dbObj{Namespace: "antsObj", Id: "obj"})
+	if err != ErrStale {
+		t.Fatal("expected ErrStale from the store", err)
+	}
+	st, err := adb1.FullStatus(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Stale {
+		t.Fatal("status not stale")
+	}
+
+	// A peer rebroadcasting its heads brings it back
+	adb2, h2 := makeTestingHost(t, WithRebroadcastDuration(100*time.Millisecond))
+	defer adb2.Close()
+
+	err = adb2.Put(context.TODO(), "other", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connectHosts(t, h1, h2)
+	<-time.After(2 * time.Second)
+	if adb1.Stale() {
+		t.Fatal("expected fresh data")
+	}
+	_, err = adb1.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStalenessWarn(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithStalenessThreshold(100*time.Millisecond, StaleWarn))
+	defer adb.Close()
+
+	err := adb.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(300 * time.Millisecond)
+	if !adb.Stale() {
+		t.Fatal("expected stale data")
+	}
+	_, err = adb.Get(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
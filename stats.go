@@ -0,0 +1,73 @@
+package antsdb
+
+import "sync/atomic"
+
+type ResourceStats struct {
+	// Goroutines started and owned by AntsDB. Goroutines internal to the
+	// CRDT datastore, pubsub or ipfs-lite are not included.
+	Goroutines int
+	// Resources like the DAG syncer and CRDT datastore which are yet to be
+	// closed.
+	OpenResources int
+}
+
+func (a *AntsDB) ResourceStats() ResourceStats {
+	return ResourceStats{
+		Goroutines:    int(atomic.LoadInt64(&a.routines)),
+		OpenResources: int(atomic.LoadInt64(&a.resources)),
+	}
+}
+
+// spawn runs fn on a goroutine tracked by AntsDB. Close waits for all of
+// them to return, so fn must exit once a.ctx is cancelled.
+func (a *AntsDB) spawn(fn func()) {
+	a.wg.Add(1)
+	atomic.AddInt64(&a.routines, 1)
+	go func() {
+		defer a.wg.Done()
+		defer atomic.AddInt64(&a.routines, -1)
+		fn()
+	}()
+}
+
+func (a *AntsDB) openResource() {
+	atomic.AddInt64(&a.resources, 1)
+}
+
+func (a *AntsDB) closeResource() {
+	atomic.AddInt64(&a.resources, -1)
+}
+
+type Stats struct {
+	// Broadcasts received from peers and not yet handed to the CRDT. Only
+	// tracked with WithMaxQueuedJobs.
+	QueuedBroadcasts int
+	QueuedBytes      int
+	// Concurrent writes resolved by the LWW rules, see conflicts for how
+	// they are detected.
+	ConflictsResolved uint64
+	// Heads waiting for the broadcast rate limit, and broadcasts which were
+	// merged into a waiting one. Only tracked with WithBroadcastRateLimit.
+	QueuedOutbound      int
+	CoalescedBroadcasts uint64
+	// Completed GC runs, manual or automatic, and the blocks they removed
+	GCRuns          uint64
+	GCRemovedBlocks uint64
+}
+
+func (a *AntsDB) Stats() Stats {
+	st := Stats{
+		ConflictsResolved: atomic.LoadUint64(&a.conflicts.total),
+		GCRuns:            atomic.LoadUint64(&a.gc.runs),
+		GCRemovedBlocks:   atomic.LoadUint64(&a.gc.removed),
+	}
+	if b, ok := a.bcast.(*pubsubBroadcaster); ok {
+		if b.queue != nil {
+			st.QueuedBroadcasts, st.QueuedBytes = b.queue.stats()
+		}
+		if b.limiter != nil {
+			st.QueuedOutbound, st.CoalescedBroadcasts = b.limiter.stats()
+		}
+	}
+	return st
+}
@@ -0,0 +1,47 @@
+package antsdb
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestResourceStats(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+
+	st := adb.ResourceStats()
+	if st.Goroutines == 0 {
+		t.Fatal("expected running goroutines", st)
+	}
+	if st.OpenResources != 2 {
+		t.Fatal("expected DAG syncer and CRDT datastore to be open", st)
+	}
+
+	adb.Close()
+
+	st = adb.ResourceStats()
+	if st.Goroutines != 0 || st.OpenResources != 0 {
+		t.Fatal("resources leaked after close", st)
+	}
+}
+
+// 64-bit atomics panic on 32-bit platforms unless the field is 8-byte aligned
+func TestAtomicAlignment(t *testing.T) {
+	a := AntsDB{}
+	r := rateLimiter{}
+	c := conflicts{}
+	for name, offset := range map[string]uintptr{
+		"routines":     unsafe.Offsetof(a.routines),
+		"resources":    unsafe.Offsetof(a.resources),
+		"lastRemote":   unsafe.Offsetof(a.lastRemote),
+		"watcherCount": unsafe.Offsetof(a.watcherCount),
+		"gc.runs":      unsafe.Offsetof(a.gc) + unsafe.Offsetof(a.gc.runs),
+		"gc.removed":   unsafe.Offsetof(a.gc) + unsafe.Offsetof(a.gc.removed),
+		"coalesced":    unsafe.Offsetof(r.coalesced),
+		"total":        unsafe.Offsetof(c.total),
+		"walks":        unsafe.Offsetof(c.walks),
+	} {
+		if offset%8 != 0 {
+			t.Fatal("field not 64-bit aligned", name, offset)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package antsdb
+
+import (
+	"context"
+	"time"
+)
+
+type NetworkStatus struct {
+	// Peers connected to the host
+	Peers int
+	// Peers subscribed to the channel
+	TopicPeers int
+}
+
+type StorageStatus struct {
+	Heads  int
+	Blocks int
+}
+
+// FullStatus collects all the introspection for status pages. A section
+// which could not be read is left empty and its error recorded in Errors
+// under the name of the field.
+type FullStatus struct {
+	Stats            Stats
+	Resources        ResourceStats
+	Network          NetworkStatus
+	Storage          StorageStatus
+	PendingHeads     int
+	SyncState        SyncState
+	Stale            bool
+	LastRemoteUpdate time.Time
+	// Time since the last update from a peer, zero if there was none
+	SyncLag time.Duration
+
+	Errors map[string]error
+}
+
+func (a *AntsDB) FullStatus(ctx context.Context) (FullStatus, error) {
+	st := FullStatus{
+		Stats:            a.Stats(),
+		Resources:        a.ResourceStats(),
+		LastRemoteUpdate: a.LastRemoteUpdate(),
+		Errors:           make(map[string]error),
+	}
+	if !st.LastRemoteUpdate.IsZero() {
+		st.SyncLag = time.Since(st.LastRemoteUpdate)
+	}
+	if a.host != nil {
+		st.Network.Peers = len(a.host.Network().Peers())
+	}
+	st.Network.TopicPeers = a.topicPeers()
+
+	pending, err := a.pendingHeads(ctx)
+	if err != nil {
+		st.Errors["PendingHeads"] = err
+	}
+	st.PendingHeads = len(pending)
+	st.SyncState = a.syncState(ctx)
+	st.Stale = a.Stale()
+
+	heads, err := a.currentHeads(ctx)
+	if err != nil {
+		st.Errors["Storage"] = err
+	} else {
+		st.Storage.Heads = len(heads)
+		st.Storage.Blocks, err = a.blockCount(ctx)
+		if err != nil {
+			st.Errors["Storage"] = err
+		}
+	}
+	return st, ctx.Err()
+}
+
+func (a *AntsDB) blockCount(ctx context.Context) (int, error) {
+	keys, err := a.blocks.AllKeysChan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for range keys {
+		count++
+	}
+	return count, ctx.Err()
+}
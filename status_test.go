@@ -0,0 +1,61 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFullStatus(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+
+	err := adb1.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	for {
+		_, err := adb2.Get(context.TODO(), "key")
+		if err == nil {
+			break
+		}
+		if time.Since(started) > 10*time.Second {
+			t.Fatal("update not received", err)
+		}
+		<-time.After(100 * time.Millisecond)
+	}
+
+	st, err := adb2.FullStatus(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Errors) != 0 {
+		t.Fatal("unexpected errors", st.Errors)
+	}
+	if st.Network.Peers == 0 || st.Network.TopicPeers == 0 {
+		t.Fatal("peers not reported", st.Network)
+	}
+	if st.Storage.Heads != 1 || st.Storage.Blocks == 0 {
+		t.Fatal("incorrect storage status", st.Storage)
+	}
+	if st.LastRemoteUpdate.IsZero() || st.SyncLag == 0 {
+		t.Fatal("remote updates not reported")
+	}
+
+	// A failing section does not hide the others
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	st, err = adb2.FullStatus(ctx)
+	if err == nil {
+		t.Fatal("expected context error")
+	}
+	if st.Network.Peers == 0 {
+		t.Fatal("partial status missing")
+	}
+}
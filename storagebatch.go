@@ -0,0 +1,254 @@
+package antsdb
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// WithStorageWriteBatching buffers the writes AntsDB makes to its storage and
+// commits them to the backend in batches of up to size operations, or every
+// flush interval, whichever comes first. This helps backends with a high cost
+// per write, such as networked datastores, as the CRDT writes a handful of
+// keys for every delta. Reads, queries included, see the buffered writes. Sync
+// and Close flush the buffer. Blocks are always written to
+// the backend directly.
+//
+// Buffered writes are lost if the process crashes before they are flushed,
+// and that includes the CRDT's own bookkeeping. Local writes acknowledged in
+// that window may be lost for good; remote ones are merged again once peers
+// rebroadcast their heads.
+func WithStorageWriteBatching(size int, flush time.Duration) Option {
+	return func(a *AntsDB) {
+		a.storeBatchSize = size
+		a.storeFlush = flush
+	}
+}
+
+type bufferedOp struct {
+	value   []byte
+	deleted bool
+}
+
+// bufferedStore coalesces writes to the backend storage
+type bufferedStore struct {
+	ds.Batching
+	mtx     sync.Mutex
+	max     int
+	pending map[ds.Key]bufferedOp
+}
+
+func newBufferedStore(backend ds.Batching, size int) *bufferedStore {
+	return &bufferedStore{
+		Batching: backend,
+		max:      size,
+		pending:  make(map[ds.Key]bufferedOp),
+	}
+}
+
+func (s *bufferedStore) add(ctx context.Context, ops map[ds.Key]bufferedOp) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for k, op := range ops {
+		s.pending[k] = op
+	}
+	if len(s.pending) >= s.max {
+		return s.flushLocked(ctx)
+	}
+	return nil
+}
+
+func (s *bufferedStore) flush(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.flushLocked(ctx)
+}
+
+func (s *bufferedStore) flushLocked(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	b, err := s.Batching.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for k, op := range s.pending {
+		if op.deleted {
+			err = b.Delete(ctx, k)
+		} else {
+			err = b.Put(ctx, k, op.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err = b.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	s.pending = make(map[ds.Key]bufferedOp)
+	return nil
+}
+
+func (s *bufferedStore) lookup(key ds.Key) (bufferedOp, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	op, found := s.pending[key]
+	return op, found
+}
+
+func (s *bufferedStore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	op := bufferedOp{value: append([]byte(nil), value...)}
+	return s.add(ctx, map[ds.Key]bufferedOp{key: op})
+}
+
+func (s *bufferedStore) Delete(ctx context.Context, key ds.Key) error {
+	return s.add(ctx, map[ds.Key]bufferedOp{key: {deleted: true}})
+}
+
+func (s *bufferedStore) Get(ctx context.Context, key ds.Key) ([]byte, error) {
+	if op, found := s.lookup(key); found {
+		if op.deleted {
+			return nil, ds.ErrNotFound
+		}
+		return append([]byte(nil), op.value...), nil
+	}
+	return s.Batching.Get(ctx, key)
+}
+
+func (s *bufferedStore) Has(ctx context.Context, key ds.Key) (bool, error) {
+	if op, found := s.lookup(key); found {
+		return !op.deleted, nil
+	}
+	return s.Batching.Has(ctx, key)
+}
+
+func (s *bufferedStore) GetSize(ctx context.Context, key ds.Key) (int, error) {
+	if op, found := s.lookup(key); found {
+		if op.deleted {
+			return -1, ds.ErrNotFound
+		}
+		return len(op.value), nil
+	}
+	return s.Batching.GetSize(ctx, key)
+}
+
+func queryPrefix(prefix string) string {
+	if len(prefix) == 0 || prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	prefix = path.Clean(prefix)
+	if prefix != "/" {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// Query merges the buffered writes under the prefix into the backend results.
+// Without any, the backend answers on its own.
+func (s *bufferedStore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	prefix := queryPrefix(q.Prefix)
+	s.mtx.Lock()
+	pending := make(map[string]bufferedOp)
+	for k, op := range s.pending {
+		if strings.HasPrefix(k.String(), prefix) {
+			pending[k.String()] = op
+		}
+	}
+	s.mtx.Unlock()
+	if len(pending) == 0 {
+		return s.Batching.Query(ctx, q)
+	}
+
+	base := query.Query{Prefix: q.Prefix, KeysOnly: q.KeysOnly, ReturnsSizes: q.ReturnsSizes}
+	results, err := s.Batching.Query(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	entries := []query.Entry{}
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if _, found := pending[r.Key]; !found {
+			entries = append(entries, r.Entry)
+		}
+	}
+	for k, op := range pending {
+		if op.deleted {
+			continue
+		}
+		e := query.Entry{Key: k, Size: len(op.value)}
+		if !q.KeysOnly {
+			e.Value = append([]byte(nil), op.value...)
+		}
+		entries = append(entries, e)
+	}
+	return query.NaiveQueryApply(q, query.ResultsWithEntries(base, entries)), nil
+}
+
+func (s *bufferedStore) Sync(ctx context.Context, prefix ds.Key) error {
+	err := s.flush(ctx)
+	if err != nil {
+		return err
+	}
+	return s.Batching.Sync(ctx, prefix)
+}
+
+func (s *bufferedStore) Close() error {
+	err := s.flush(context.Background())
+	if err != nil {
+		return err
+	}
+	return s.Batching.Close()
+}
+
+func (s *bufferedStore) Batch(ctx context.Context) (ds.Batch, error) {
+	return &bufferedBatch{s: s, ops: make(map[ds.Key]bufferedOp)}, nil
+}
+
+type bufferedBatch struct {
+	s   *bufferedStore
+	ops map[ds.Key]bufferedOp
+}
+
+func (b *bufferedBatch) Put(ctx context.Context, key ds.Key, value []byte) error {
+	b.ops[key] = bufferedOp{value: append([]byte(nil), value...)}
+	return nil
+}
+
+func (b *bufferedBatch) Delete(ctx context.Context, key ds.Key) error {
+	b.ops[key] = bufferedOp{deleted: true}
+	return nil
+}
+
+func (b *bufferedBatch) Commit(ctx context.Context) error {
+	return b.s.add(ctx, b.ops)
+}
+
+func (a *AntsDB) flushStorage() {
+	ticker := time.NewTicker(a.storeFlush)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			err := a.buffered.flush(a.ctx)
+			if err != nil {
+				log.Errorf("Failed flushing storage writes Err:%s", err.Error())
+			}
+		}
+	}
+}
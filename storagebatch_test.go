@@ -0,0 +1,213 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	ds "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	libp2p "github.com/libp2p/go-libp2p"
+	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
+)
+
+// slowStore charges a fixed latency for every write reaching the backend.
+// Only AntsDB's own writes are counted, ipfs-lite keeps its blocks and
+// provider records under /ant/b with keys of several segments.
+type slowStore struct {
+	ds.Batching
+	latency time.Duration
+	writes  int64
+}
+
+func counted(key ds.Key) bool {
+	rest := strings.TrimPrefix(key.String(), "/ant/b/")
+	return rest == key.String() || !strings.Contains(rest, "/")
+}
+
+func (s *slowStore) write(key ds.Key) {
+	if counted(key) {
+		atomic.AddInt64(&s.writes, 1)
+	}
+	time.Sleep(s.latency)
+}
+
+func (s *slowStore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	s.write(key)
+	return s.Batching.Put(ctx, key, value)
+}
+
+func (s *slowStore) Delete(ctx context.Context, key ds.Key) error {
+	s.write(key)
+	return s.Batching.Delete(ctx, key)
+}
+
+func (s *slowStore) Batch(ctx context.Context) (ds.Batch, error) {
+	b, err := s.Batching.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &slowBatch{Batch: b, s: s}, nil
+}
+
+type slowBatch struct {
+	ds.Batch
+	s       *slowStore
+	counted bool
+}
+
+func (b *slowBatch) Put(ctx context.Context, key ds.Key, value []byte) error {
+	b.counted = b.counted || counted(key)
+	return b.Batch.Put(ctx, key, value)
+}
+
+func (b *slowBatch) Delete(ctx context.Context, key ds.Key) error {
+	b.counted = b.counted || counted(key)
+	return b.Batch.Delete(ctx, key)
+}
+
+func (b *slowBatch) Commit(ctx context.Context) error {
+	if b.counted {
+		atomic.AddInt64(&b.s.writes, 1)
+	}
+	time.Sleep(b.s.latency)
+	return b.Batch.Commit(ctx)
+}
+
+func newLocalAntsDB(t testing.TB, storage ds.Batching, opts ...Option) *AntsDB {
+	h, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idht, err := dual.New(context.TODO(), h)
+	if err != nil {
+		h.Close()
+		t.Fatal(err)
+	}
+	opts = append(opts, WithOnCloseHook(func() {
+		h.Close()
+		idht.Close()
+	}))
+	adb, err := New(h, idht, nil, storage, opts...)
+	if err != nil {
+		h.Close()
+		idht.Close()
+		t.Fatal(err)
+	}
+	return adb
+}
+
+func TestStorageWriteBatching(t *testing.T) {
+	backend := &slowStore{Batching: syncds.MutexWrap(datastore.NewMapDatastore())}
+	adb := newLocalAntsDB(t, backend, WithStorageWriteBatching(1000, time.Hour))
+
+	for i := 0; i < 20; i++ {
+		err := adb.Put(context.TODO(), fmt.Sprintf("key%d", i), []byte("val"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w := atomic.LoadInt64(&backend.writes); w != 0 {
+		t.Fatal("writes reached the backend before flush", w)
+	}
+	// Reads see buffered writes
+	val, err := adb.Get(context.TODO(), "key7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value", string(val))
+	}
+	err = adb.DeleteKey(context.TODO(), "key7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.Get(context.TODO(), "key7")
+	if err != ds.ErrNotFound {
+		t.Fatal("expected deleted key", err)
+	}
+
+	err = adb.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := atomic.LoadInt64(&backend.writes); w != 1 {
+		t.Fatal("expected a single flush on close", w)
+	}
+
+	// Everything made it to the backend
+	adb = newLocalAntsDB(t, backend)
+	defer adb.Close()
+	for i := 0; i < 20; i++ {
+		_, err := adb.Get(context.TODO(), fmt.Sprintf("key%d", i))
+		if i == 7 {
+			if err != ds.ErrNotFound {
+				t.Fatal("deleted key found after reopen", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal("key lost after reopen", i, err)
+		}
+	}
+}
+
+func TestStorageWriteBatchingOverlap(t *testing.T) {
+	backend := syncds.MutexWrap(datastore.NewMapDatastore())
+	adb := newLocalAntsDB(t, backend, WithStorageWriteBatching(100, time.Hour))
+	defer adb.Close()
+
+	h, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	_, err = New(h, nil, nil, backend, WithStorageWriteBatching(100, time.Hour))
+	if !errors.Is(err, ErrNamespaceOverlap) {
+		t.Fatal("expected ErrNamespaceOverlap with batching on both", err)
+	}
+	_, err = New(h, nil, nil, backend)
+	if !errors.Is(err, ErrNamespaceOverlap) {
+		t.Fatal("expected ErrNamespaceOverlap with batching on one", err)
+	}
+}
+
+func BenchmarkStorageWriteBatching(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Unbatched", nil},
+		{"Batched", []Option{WithStorageWriteBatching(512, 50*time.Millisecond)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			backend := &slowStore{
+				Batching: syncds.MutexWrap(datastore.NewMapDatastore()),
+				latency:  200 * time.Microsecond,
+			}
+			adb := newLocalAntsDB(b, backend, bc.opts...)
+			defer adb.Close()
+
+			val := []byte("val")
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := adb.Put(context.TODO(), fmt.Sprintf("key%d", i), val)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(&backend.writes))/float64(b.N), "writes/op")
+		})
+	}
+}
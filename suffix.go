@@ -0,0 +1,32 @@
+package antsdb
+
+import (
+	"context"
+	"strings"
+)
+
+type KV struct {
+	Key   string
+	Value []byte
+}
+
+// ListBySuffix returns up to limit keys ending with suffix, or all of them if
+// limit is not positive. The datastore only indexes keys by prefix, so this
+// scans and decodes every value in the CRDT: its cost grows with the whole
+// dataset, not with the number of matches. It suits small datasets and
+// occasional queries. For hot paths keep a secondary index instead, writing
+// every key reversed to its own namespace along with the key itself, which
+// doubles the number of keys stored and replicated. WithTransform applies to
+// the values returned.
+func (a *AntsDB) ListBySuffix(ctx context.Context, suffix string, limit int, opts ...OpOption) ([]KV, error) {
+	res := []KV{}
+	match := func(key string) bool { return strings.HasSuffix(key, suffix) }
+	err := a.scan(ctx, "/", match, newOpConfig(opts), func(key string, val []byte) (bool, error) {
+		res = append(res, KV{Key: key, Value: val})
+		return limit > 0 && len(res) == limit, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,45 @@
+package antsdb
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestListBySuffix(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	for _, k := range []string{"events/1/click", "events/2/view", "events/3/click", "clicks"} {
+		err := adb.Put(context.TODO(), k, []byte(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := adb.PutWithTTL(context.TODO(), "events/4/click", []byte("expired"), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(10 * time.Millisecond)
+
+	kvs, err := adb.ListBySuffix(context.TODO(), "/click", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	if len(kvs) != 2 || kvs[0].Key != "/events/1/click" || kvs[1].Key != "/events/3/click" {
+		t.Fatal("incorrect keys", kvs)
+	}
+	if string(kvs[0].Value) != "events/1/click" {
+		t.Fatal("incorrect value", string(kvs[0].Value))
+	}
+
+	kvs, err = adb.ListBySuffix(context.TODO(), "click", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 1 {
+		t.Fatal("limit not applied", kvs)
+	}
+}
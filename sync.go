@@ -0,0 +1,114 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var (
+	syncPollInterval = 500 * time.Millisecond
+	// A state is reported once it held for this long
+	syncDebounce = 2 * time.Second
+	// Peers rebroadcast their heads every interval, so going this many
+	// intervals without hearing from any of them means the node is cut off
+	// even if the peers are still subscribed.
+	syncSilentIntervals = 10
+)
+
+type SyncState int
+
+const (
+	// Syncing means heads announced by peers are still being fetched or
+	// processed.
+	Syncing SyncState = iota
+	// Synced means everything announced by peers has been merged.
+	Synced
+	// Isolated means there are no peers to sync with.
+	Isolated
+)
+
+func (s SyncState) String() string {
+	switch s {
+	case Syncing:
+		return "syncing"
+	case Synced:
+		return "synced"
+	case Isolated:
+		return "isolated"
+	}
+	return "unknown"
+}
+
+type syncWatch struct {
+	mtx   sync.Mutex
+	once  sync.Once
+	hooks []func(SyncState)
+}
+
+func (a *AntsDB) syncState(ctx context.Context) SyncState {
+	if a.topicPeers() == 0 {
+		return Isolated
+	}
+	last := a.LastRemoteUpdate()
+	silent := time.Duration(syncSilentIntervals) * a.rebcastInterval
+	if !last.IsZero() && time.Since(last) > silent {
+		return Isolated
+	}
+	if st := a.Stats(); st.QueuedBroadcasts > 0 {
+		return Syncing
+	}
+	pending, err := a.pendingHeads(ctx)
+	if err != nil {
+		log.Errorf("Failed checking pending heads Err:%s", err.Error())
+		return Syncing
+	}
+	if len(pending) > 0 {
+		return Syncing
+	}
+	return Synced
+}
+
+// OnSyncStateChange calls fn with the state the node settles in first and
+// then on every transition. Short lived states, like syncing a single
+// delta, are not reported: a state is only reported once it held for a
+// couple of seconds. fn is called from a single goroutine, in order.
+func (a *AntsDB) OnSyncStateChange(fn func(state SyncState)) {
+	w := &a.syncWatch
+	w.mtx.Lock()
+	w.hooks = append(w.hooks, fn)
+	w.mtx.Unlock()
+
+	w.once.Do(func() { a.spawn(a.watchSyncState) })
+}
+
+func (a *AntsDB) watchSyncState() {
+	ticker := time.NewTicker(syncPollInterval)
+	defer ticker.Stop()
+
+	var (
+		reported  SyncState = -1
+		candidate SyncState = -1
+		since     time.Time
+	)
+	for {
+		state := a.syncState(a.ctx)
+		if state != candidate {
+			candidate, since = state, time.Now()
+		}
+		if candidate != reported && time.Since(since) >= syncDebounce {
+			reported = candidate
+			a.syncWatch.mtx.Lock()
+			hooks := append([]func(SyncState){}, a.syncWatch.hooks...)
+			a.syncWatch.mtx.Unlock()
+			for _, fn := range hooks {
+				fn(reported)
+			}
+		}
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
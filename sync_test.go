@@ -0,0 +1,47 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSyncStateChange(t *testing.T) {
+	defer func(d time.Duration) { syncDebounce = d }(syncDebounce)
+	syncDebounce = 300 * time.Millisecond
+
+	adb1, h1 := makeTestingHost(t)
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	states := make(chan SyncState, 10)
+	adb2.OnSyncStateChange(func(s SyncState) { states <- s })
+
+	waitFor := func(want SyncState) {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case s := <-states:
+				if s == want {
+					return
+				}
+			case <-timeout:
+				t.Fatal("timed out waiting for state", want)
+			}
+		}
+	}
+
+	waitFor(Isolated)
+
+	err := adb1.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connectHosts(t, h1, h2)
+	waitFor(Synced)
+
+	// Losing the only peer
+	adb1.Close()
+	waitFor(Isolated)
+}
@@ -0,0 +1,142 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var ErrTokenTimeout = errors.New("node did not catch up to the consistency token in time")
+
+// Waiting on a token without a deadline in ctx is bounded by this
+var tokenWaitTimeout = 10 * time.Second
+
+// Token identifies the state of a replica as the DAG heads it had when the
+// token was taken. A node holding all of them has merged every write the
+// token was taken after.
+type Token struct {
+	Heads []cid.Cid
+}
+
+// String encodes the token so it can travel along with a request.
+func (t Token) String() string {
+	heads := make([]string, len(t.Heads))
+	for i, c := range t.Heads {
+		heads[i] = c.String()
+	}
+	return strings.Join(heads, ",")
+}
+
+// ParseToken decodes a token encoded with Token.String.
+func ParseToken(s string) (Token, error) {
+	t := Token{}
+	if s == "" {
+		return t, nil
+	}
+	for _, h := range strings.Split(s, ",") {
+		c, err := cid.Decode(h)
+		if err != nil {
+			return Token{}, err
+		}
+		t.Heads = append(t.Heads, c)
+	}
+	return t, nil
+}
+
+// Token returns the token of the local replica. Taken right after a write,
+// it lets GetConsistent on any other node read that write or a newer value,
+// unless another write on this node lands in between; PutWithToken and
+// DeleteWithToken return the token of the write itself.
+func (a *AntsDB) Token(ctx context.Context) (Token, error) {
+	if a.packer != nil {
+		err := a.flushPacked(ctx)
+		if err != nil {
+			return Token{}, err
+		}
+	}
+	heads, err := a.currentHeads(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Heads: heads}, nil
+}
+
+// PutWithToken is Put returning the token of the delta it committed, so
+// writers racing on this node do not end up in the token. The value is
+// written in a delta of its own even with WithValuePacking.
+func (a *AntsDB) PutWithToken(ctx context.Context, key string, val []byte) (Token, error) {
+	ctx, span := a.startSpan(ctx, "PutWithToken", attribute.String("key", key), attribute.Int("size", len(val)))
+	token := Token{}
+	err := a.put(ctx, key, val, &token)
+	endSpan(span, err)
+	return token, err
+}
+
+// DeleteWithToken is DeleteKey returning the token of the delta it
+// committed.
+func (a *AntsDB) DeleteWithToken(ctx context.Context, key string) (Token, error) {
+	ctx, span := a.startSpan(ctx, "DeleteWithToken", attribute.String("key", key))
+	token := Token{}
+	err := a.deleteKey(ctx, key, &token)
+	endSpan(span, err)
+	return token, err
+}
+
+// collectHeads makes the local deltas added from now on go to token. Batch
+// commits are serialized, but plain Puts of the CRDT are not, so a delta of
+// one of those landing meanwhile is collected as well. That only makes the
+// token stricter.
+func (a *AntsDB) collectHeads(token *Token) {
+	a.collectMtx.Lock()
+	defer a.collectMtx.Unlock()
+
+	a.collecting = token
+}
+
+func (a *AntsDB) collected(c cid.Cid) {
+	a.collectMtx.Lock()
+	defer a.collectMtx.Unlock()
+
+	if a.collecting != nil {
+		a.collecting.Heads = append(a.collecting.Heads, c)
+	}
+}
+
+// GetConsistent reads key once this node has caught up to token, so a read
+// following a write on another node observes it. Heads of the token this
+// node has not merged yet are fetched as if a peer had just announced them,
+// instead of waiting for the next broadcast.
+//
+// Catching up is bounded by ctx, or by 10 seconds if ctx has no deadline;
+// ErrTokenTimeout is returned if the node is still behind by then. The
+// deltas of the token are merged before reading, but older deltas they
+// build on may still be merging if this node had never seen their branch.
+func (a *AntsDB) GetConsistent(ctx context.Context, key string, token Token) ([]byte, error) {
+	waitCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, tokenWaitTimeout)
+		defer cancel()
+	}
+	for _, c := range token.Heads {
+		done, err := a.isProcessed(waitCtx, c)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			continue
+		}
+		err = a.applyDelta(waitCtx, c)
+		if errors.Is(err, context.DeadlineExceeded) && a.ctx.Err() == nil {
+			return nil, ErrTokenTimeout
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a.Get(ctx, key)
+}
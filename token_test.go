@@ -0,0 +1,105 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+func TestGetConsistent(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+
+	err := adb1.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := adb1.Token(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseToken(token.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Heads) != 1 || !parsed.Heads[0].Equals(token.Heads[0]) {
+		t.Fatal("token changed on encoding", parsed, token)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	val, err := adb2.GetConsistent(ctx, "key", parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value", string(val))
+	}
+
+	// Nobody has this delta
+	missing := Token{Heads: []cid.Cid{dag.NodeWithData([]byte("missing")).Cid()}}
+	ctx, cancel = context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	_, err = adb2.GetConsistent(ctx, "key", missing)
+	if err != ErrTokenTimeout {
+		t.Fatal("expected ErrTokenTimeout", err)
+	}
+}
+
+func TestWriteWithToken(t *testing.T) {
+	adb1, h1 := makeTestingHost(t, WithValuePacking(64))
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+
+	// Packed, so only the value written with a token is committed
+	err := adb1.Put(context.TODO(), "other", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := adb1.PutWithToken(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token.Heads) != 1 {
+		t.Fatal("expected the delta of the write", token)
+	}
+	heads, err := adb1.currentHeads(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heads) != 1 || !heads[0].Equals(token.Heads[0]) {
+		t.Fatal("token is not the head after the write", heads, token)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	val, err := adb2.GetConsistent(ctx, "key", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "val" {
+		t.Fatal("incorrect value", string(val))
+	}
+
+	token, err = adb1.DeleteWithToken(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb2.GetConsistent(ctx, "key", token)
+	if err != ds.ErrNotFound {
+		t.Fatal("expected the delete", err)
+	}
+}
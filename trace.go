@@ -0,0 +1,33 @@
+package antsdb
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracer records spans for reads, writes, Clean and the DAG fetches
+// done while merging peers' deltas. The default tracer discards them.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(a *AntsDB) {
+		a.tracer = tracer
+	}
+}
+
+func (a *AntsDB) startSpan(
+	ctx context.Context,
+	name string,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return a.tracer.Start(ctx, "antsdb."+name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
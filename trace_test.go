@@ -0,0 +1,119 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type testSpan struct {
+	trace.Span
+	name   string
+	attrs  map[attribute.Key]attribute.Value
+	failed bool
+	ended  bool
+}
+
+func (s *testSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) SetStatus(code codes.Code, _ string) { s.failed = code == codes.Error }
+
+func (s *testSpan) End(...trace.SpanEndOption) { s.ended = true }
+
+type testTracer struct {
+	mtx   sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	_, noop := trace.NewNoopTracerProvider().Tracer("").Start(ctx, name)
+	s := &testSpan{Span: noop, name: name, attrs: map[attribute.Key]attribute.Value{}}
+	cfg := trace.NewSpanStartConfig(opts...)
+	s.SetAttributes(cfg.Attributes()...)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.spans = append(t.spans, s)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (t *testTracer) find(name string) []*testSpan {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	found := []*testSpan{}
+	for _, s := range t.spans {
+		if s.name == name {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &testTracer{}
+	adb1, h1 := makeTestingHost(t, WithTracer(tracer))
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	err := adb1.Put(context.TODO(), "key", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb1.Get(context.TODO(), "missing")
+	if err == nil {
+		t.Fatal("expected missing key")
+	}
+	err = adb1.DeleteKey(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puts := tracer.find("antsdb.Put")
+	if len(puts) != 1 || !puts[0].ended || puts[0].failed {
+		t.Fatal("incorrect put span", puts)
+	}
+	if puts[0].attrs["key"].AsString() != "key" || puts[0].attrs["size"].AsInt64() != 3 {
+		t.Fatal("incorrect put attributes", puts[0].attrs)
+	}
+	gets := tracer.find("antsdb.Get")
+	if len(gets) != 1 || !gets[0].failed {
+		t.Fatal("expected failed get span", gets)
+	}
+	if len(tracer.find("antsdb.Delete")) != 1 {
+		t.Fatal("expected delete span")
+	}
+
+	err = adb2.Put(context.TODO(), "remote", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(time.Second * 2)
+
+	fetched := false
+	for _, s := range append(tracer.find("antsdb.FetchDelta"), tracer.find("antsdb.FetchDeltas")...) {
+		fetched = fetched || (s.ended && !s.failed)
+	}
+	if !fetched {
+		t.Fatal("expected DAG fetch span")
+	}
+}
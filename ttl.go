@@ -0,0 +1,137 @@
+package antsdb
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var ttlNs = "/_ttl"
+
+func WithTTLSweepInterval(d time.Duration) Option {
+	return func(a *AntsDB) {
+		a.ttlSweep = d
+	}
+}
+
+func ttlKey(key string) ds.Key {
+	return ds.NewKey(ttlNs).Child(ds.NewKey(key))
+}
+
+func encodeExpiry(ttl time.Duration) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(time.Now().Add(ttl).UnixNano()))
+	return buf
+}
+
+func decodeExpiry(buf []byte) time.Time {
+	if len(buf) != 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(buf)))
+}
+
+func (a *AntsDB) PutWithTTL(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	key, val, err := a.encodeEntry(ctx, key, val)
+	if err != nil {
+		return err
+	}
+	if a.packer != nil {
+		a.packer.drop(key)
+	}
+	batch, err := a.local.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	err = batch.Put(ctx, ds.NewKey(key), val)
+	if err != nil {
+		return err
+	}
+	err = batch.Put(ctx, ttlKey(key), encodeExpiry(ttl))
+	if err != nil {
+		return err
+	}
+	return batch.Commit(ctx)
+}
+
+// GetRefreshTTL reads the key and pushes its expiry ttl into the future,
+// giving "expire ttl after last access" semantics. Every successful read
+// publishes a new delta for the expiry record, so on read-heavy keys this
+// adds DAG growth and broadcast traffic proportional to the read rate.
+func (a *AntsDB) GetRefreshTTL(ctx context.Context, key string, ttl time.Duration) ([]byte, error) {
+	val, err := a.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	key, _, err = a.storedKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = a.local.Put(ctx, ttlKey(key), encodeExpiry(ttl))
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (a *AntsDB) isExpired(ctx context.Context, key string) (bool, error) {
+	buf, err := a.crdt.Get(ctx, ttlKey(key))
+	if err != nil {
+		if err == ds.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return time.Now().After(decodeExpiry(buf)), nil
+}
+
+func (a *AntsDB) sweepExpired() {
+	ticker := time.NewTicker(a.ttlSweep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			err := a.deleteExpired(a.ctx)
+			if err != nil {
+				log.Errorf("Failed sweeping expired keys Err:%s", err.Error())
+			}
+		}
+	}
+}
+
+func (a *AntsDB) deleteExpired(ctx context.Context) error {
+	results, err := a.crdt.Query(ctx, query.Query{Prefix: ttlNs})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	expired := []string{}
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		if time.Now().After(decodeExpiry(r.Value)) {
+			expired = append(expired, strings.TrimPrefix(r.Key, ttlNs))
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	log.Infof("Removing %d expired keys", len(expired))
+	for _, key := range expired {
+		err := a.deleteStored(ctx, key, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package antsdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestTTLExpiry(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithTTLSweepInterval(200*time.Millisecond))
+	defer adb.Close()
+
+	err := adb.PutWithTTL(context.TODO(), "session/1", []byte("val"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := adb.Get(context.TODO(), "session/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, []byte("val")) {
+		t.Fatal("incorrect value", string(val))
+	}
+
+	<-time.After(1500 * time.Millisecond)
+
+	_, err = adb.Get(context.TODO(), "session/1")
+	if err != ds.ErrNotFound {
+		t.Fatal("expected key to expire", err)
+	}
+}
+
+func TestGetRefreshTTL(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithTTLSweepInterval(200*time.Millisecond))
+	defer adb.Close()
+
+	err := adb.PutWithTTL(context.TODO(), "session/1", []byte("val"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads within the window keep the key alive well past the original TTL
+	for i := 0; i < 4; i++ {
+		<-time.After(500 * time.Millisecond)
+		_, err := adb.GetRefreshTTL(context.TODO(), "session/1", time.Second)
+		if err != nil {
+			t.Fatal("key expired while being accessed", i, err)
+		}
+	}
+
+	<-time.After(1500 * time.Millisecond)
+
+	_, err = adb.GetRefreshTTL(context.TODO(), "session/1", time.Second)
+	if err != ds.ErrNotFound {
+		t.Fatal("expected key to expire after last access", err)
+	}
+}
+
+func TestPutClearsTTL(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithTTLSweepInterval(200*time.Millisecond))
+	defer adb.Close()
+
+	err := adb.PutWithTTL(context.TODO(), "session/1", []byte("val"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.Put(context.TODO(), "session/1", []byte("val2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(1500 * time.Millisecond)
+
+	val, err := adb.Get(context.TODO(), "session/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, []byte("val2")) {
+		t.Fatal("incorrect value", string(val))
+	}
+}
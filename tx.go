@@ -0,0 +1,103 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var ErrTxDone = errors.New("transaction already committed or discarded")
+
+type txOp struct {
+	key     string
+	val     []byte
+	deleted bool
+}
+
+// Tx collects Puts and Deletes which are committed together as a single
+// CRDT delta, and so are applied by every replica at once. Other local
+// writes are never interleaved with a transaction. The CRDT splits deltas
+// over its MaxBatchDeltaSize of 1MiB though, keys, values and tombstones
+// included; replicas may observe part of a larger transaction until the rest
+// arrives. A Tx is not safe for concurrent use.
+type Tx struct {
+	a    *AntsDB
+	ops  []txOp
+	done bool
+}
+
+func (a *AntsDB) Begin() *Tx {
+	return &Tx{a: a}
+}
+
+func (t *Tx) Put(key string, val []byte) *Tx {
+	t.ops = append(t.ops, txOp{key: key, val: append([]byte(nil), val...)})
+	return t
+}
+
+func (t *Tx) Delete(key string) *Tx {
+	t.ops = append(t.ops, txOp{key: key, deleted: true})
+	return t
+}
+
+func (t *Tx) Discard() {
+	t.ops = nil
+	t.done = true
+}
+
+// Commit writes the operations in the order they were added, so the last
+// one for a key wins. Like Put, values are stored without expiry.
+func (t *Tx) Commit(ctx context.Context) error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+
+	a := t.a
+	// Nothing is queued until every operation is valid
+	ops := make([]txOp, 0, len(t.ops))
+	for _, op := range t.ops {
+		if err := a.checkReserved(op.key); err != nil {
+			return err
+		}
+		key, hashed, err := a.storedKey(op.key)
+		if err != nil {
+			return err
+		}
+		if hashed && !op.deleted {
+			op.val = wrapHashedKey(op.key, op.val)
+		}
+		op.key = key
+		if !op.deleted {
+			op.val, err = a.encodeValue(ctx, op.val)
+			if err != nil {
+				return err
+			}
+		}
+		ops = append(ops, op)
+	}
+
+	batch, err := a.local.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if a.packer != nil {
+			a.packer.drop(op.key)
+		}
+		if op.deleted {
+			err = batch.Delete(ctx, ds.NewKey(op.key))
+		} else {
+			err = batch.Put(ctx, ds.NewKey(op.key), op.val)
+		}
+		if err != nil {
+			return err
+		}
+		err = batch.Delete(ctx, ttlKey(op.key))
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Commit(ctx)
+}
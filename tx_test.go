@@ -0,0 +1,78 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestTx(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	err := adb.Put(context.TODO(), "old", []byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := blockCount(t, adb)
+	tx := adb.Begin()
+	tx.Put("a", []byte("a")).Put("b", []byte("b")).Delete("old")
+	tx.Put("c", []byte("c")).Delete("c")
+
+	// Nothing is visible before commit
+	_, err = adb.Get(context.TODO(), "a")
+	if err != ds.ErrNotFound {
+		t.Fatal("uncommitted value visible", err)
+	}
+	err = tx.Commit(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks := blockCount(t, adb) - before; blocks != 1 {
+		t.Fatal("expected a single delta", blocks)
+	}
+	for k, exists := range map[string]bool{"a": true, "b": true, "c": false, "old": false} {
+		_, err := adb.Get(context.TODO(), k)
+		if exists && err != nil {
+			t.Fatal(k, err)
+		}
+		if !exists && err != ds.ErrNotFound {
+			t.Fatal("expected key to be deleted", k, err)
+		}
+	}
+	if tx.Commit(context.TODO()) != ErrTxDone {
+		t.Fatal("expected error on second commit")
+	}
+
+	tx = adb.Begin().Put("d", []byte("d"))
+	tx.Discard()
+	if tx.Commit(context.TODO()) != ErrTxDone {
+		t.Fatal("expected error committing discarded tx")
+	}
+	_, err = adb.Get(context.TODO(), "d")
+	if err != ds.ErrNotFound {
+		t.Fatal("discarded value visible", err)
+	}
+}
+
+func TestTxFailedCommit(t *testing.T) {
+	adb, _ := makeTestingHost(t)
+	defer adb.Close()
+
+	tx := adb.Begin().Put("first", []byte("first")).Put(ttlNs+"/bad", []byte("bad"))
+	err := tx.Commit(context.TODO())
+	if err != ErrReservedKey {
+		t.Fatal("expected reserved key error", err)
+	}
+	// The valid operations must not ride along with the next write
+	err = adb.Put(context.TODO(), "next", []byte("next"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.Get(context.TODO(), "first")
+	if err != ds.ErrNotFound {
+		t.Fatal("operation of a failed transaction committed", err)
+	}
+}
@@ -0,0 +1,47 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+var (
+	versionNs = "_version"
+	// Identifies the on-disk layout of go-ds-crdt. Bump it along with the
+	// dependency whenever the new release stores its state differently.
+	crdtVersion = "go-ds-crdt/v0.3"
+
+	ErrVersionMismatch = errors.New("storage written by an incompatible CRDT version")
+)
+
+// WithAllowVersionMismatch opens storage written by a different CRDT version
+// instead of failing with ErrVersionMismatch, and records the current
+// version. Only use it once the new version is known to read the old state;
+// otherwise Clean the storage and let the node sync again from its peers.
+func WithAllowVersionMismatch() Option {
+	return func(a *AntsDB) {
+		a.allowMismatch = true
+	}
+}
+
+func (a *AntsDB) checkVersion(ctx context.Context) error {
+	key := a.namespace.ChildString(versionNs)
+	buf, err := a.storage.Get(ctx, key)
+	switch {
+	case err == ds.ErrNotFound:
+		// Storage created before versions were recorded is assumed to be
+		// current
+	case err != nil:
+		return err
+	case string(buf) == crdtVersion:
+		return nil
+	case !a.allowMismatch:
+		return fmt.Errorf("%w: stored %s, expected %s", ErrVersionMismatch, string(buf), crdtVersion)
+	default:
+		log.Warnf("Opening storage written by %s with %s", string(buf), crdtVersion)
+	}
+	return a.storage.Put(ctx, key, []byte(crdtVersion))
+}
@@ -0,0 +1,49 @@
+package antsdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+)
+
+func TestVersionMismatch(t *testing.T) {
+	bs := syncds.MutexWrap(datastore.NewMapDatastore())
+	err := bs.Put(context.TODO(), datastore.NewKey(defaultRootNs).ChildString(versionNs), []byte("go-ds-crdt/v0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(nil, nil, nil, bs)
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Fatal("expected version mismatch", err)
+	}
+
+	adb, _ := makeTestingHost(t, WithAllowVersionMismatch())
+	defer adb.Close()
+
+	key := adb.namespace.ChildString(versionNs)
+	stored, err := adb.storage.Get(context.TODO(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stored) != crdtVersion {
+		t.Fatal("version not recorded on first init", string(stored))
+	}
+	err = adb.storage.Put(context.TODO(), key, []byte("go-ds-crdt/v0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb.checkVersion(context.TODO())
+	if err != nil {
+		t.Fatal("mismatch not allowed", err)
+	}
+	stored, err = adb.storage.Get(context.TODO(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stored) != crdtVersion {
+		t.Fatal("version not updated", string(stored))
+	}
+}
@@ -0,0 +1,115 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+// Predicates slower than this are reported, as they hold up merging
+var predicateBudget = 10 * time.Millisecond
+
+type predicateWatch struct {
+	match func(key string, val []byte) bool
+	sub   *eventSub
+	slow  int32
+}
+
+type watchers struct {
+	mtx  sync.RWMutex
+	list []*predicateWatch
+}
+
+func (w *watchers) add(pw *predicateWatch) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.list = append(w.list, pw)
+}
+
+func (w *watchers) remove(pw *predicateWatch) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for i, v := range w.list {
+		if v == pw {
+			w.list = append(w.list[:i], w.list[i+1:]...)
+			return
+		}
+	}
+}
+
+// matchWatchers runs in the CRDT put hook with the value as stored
+func (a *AntsDB) matchWatchers(ctx context.Context, key string, val []byte) {
+	a.watchers.mtx.RLock()
+	defer a.watchers.mtx.RUnlock()
+
+	if len(a.watchers.list) == 0 || a.isMetadata(key) {
+		return
+	}
+	// Never fetch from the network in the hook
+	if c, isRef := lazyRef(val); isRef {
+		if has, err := a.blocks.Has(ctx, c); err != nil || !has {
+			log.Debugf("Skipping predicates on unfetched value of %s", key)
+			return
+		}
+	}
+	val, err := a.decodeValue(ctx, val)
+	if err != nil {
+		log.Errorf("Failed decoding %s for predicates Err:%s", key, err.Error())
+		return
+	}
+	if k, orig, hashed := splitHashedKey(val); hashed {
+		key, val = ds.NewKey(k).String(), orig
+	}
+
+	for _, w := range a.watchers.list {
+		start := time.Now()
+		matched := w.match(key, val)
+		if time.Since(start) > predicateBudget && atomic.CompareAndSwapInt32(&w.slow, 0, 1) {
+			log.Warnf("WatchWhere predicate took %s on %s, merging is blocked meanwhile",
+				time.Since(start), key)
+		}
+		if matched {
+			w.sub.push(Event{Batch: []KeyOp{{Key: key, Value: append([]byte(nil), val...)}}})
+		}
+	}
+}
+
+// WatchWhere emits an Event for every put, local or merged from a peer, whose
+// key and new value satisfy match. Each Event holds the single matching
+// put; Cid and Local are not set. Deletes are not reported.
+//
+// match is called synchronously from the CRDT's put hook, for every watcher
+// and every put, so it must be cheap and must not modify val: the CRDT does
+// not merge anything else until it returns, and predicates taking longer
+// than 10ms are logged. Values stored lazily with WithLazyMaterialization
+// are only matched if they are already available locally. The channel is
+// closed once ctx is done or AntsDB is closed.
+func (a *AntsDB) WatchWhere(
+	ctx context.Context,
+	match func(key string, val []byte) bool,
+) (<-chan Event, error) {
+	if a.ctx.Err() != nil {
+		return nil, a.ctx.Err()
+	}
+	if err := a.acquireWatcher(); err != nil {
+		return nil, err
+	}
+	pw := &predicateWatch{
+		match: match,
+		sub:   &eventSub{notify: make(chan struct{}, 1)},
+	}
+	a.watchers.add(pw)
+
+	done := func() {
+		a.watchers.remove(pw)
+		a.releaseWatcher()
+	}
+	return a.streamEvents(ctx, pw.sub, done, func(ev Event) Event {
+		return ev
+	}), nil
+}
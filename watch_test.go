@@ -0,0 +1,71 @@
+package antsdb
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchWhere(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	connectHosts(t, h1, h2)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	large, err := adb1.WatchWhere(ctx, func(key string, val []byte) bool {
+		if !strings.HasPrefix(key, "/order/") {
+			return false
+		}
+		amount, err := strconv.Atoi(string(val))
+		return err == nil && amount > 1000
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, amount := range map[string]string{"order/1": "500", "other": "5000"} {
+		err := adb1.Put(context.TODO(), key, []byte(amount))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = adb1.Put(context.TODO(), "order/2", []byte("1500"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adb2.Put(context.TODO(), "order/3", []byte("2000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]string{}
+	for len(seen) < 2 {
+		select {
+		case ev := <-large:
+			if len(ev.Batch) != 1 {
+				t.Fatal("expected a single put", ev.Batch)
+			}
+			seen[ev.Batch[0].Key] = string(ev.Batch[0].Value)
+		case <-ctx.Done():
+			t.Fatal("matching puts not seen", seen)
+		}
+	}
+	if seen["/order/2"] != "1500" || seen["/order/3"] != "2000" {
+		t.Fatal("incorrect matches", seen)
+	}
+
+	cancel()
+	for ev := range large {
+		t.Fatal("unexpected match", ev.Batch)
+	}
+}
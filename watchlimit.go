@@ -0,0 +1,41 @@
+package antsdb
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var ErrTooManyWatchers = errors.New("maximum number of watchers reached")
+
+// WithMaxWatchers limits the subscriptions open at once through Events,
+// WatchWhere and Writers to n. Opening one more fails with
+// ErrTooManyWatchers until the context of an open one is done. By default
+// there is no limit.
+func WithMaxWatchers(n int) Option {
+	return func(a *AntsDB) {
+		a.maxWatchers = int64(n)
+	}
+}
+
+// WatcherCount returns the subscriptions currently open through Events,
+// WatchWhere and Writers. A subscription is closed, and stops counting,
+// once its context is done.
+func (a *AntsDB) WatcherCount() int {
+	return int(atomic.LoadInt64(&a.watcherCount))
+}
+
+func (a *AntsDB) acquireWatcher() error {
+	for {
+		n := atomic.LoadInt64(&a.watcherCount)
+		if a.maxWatchers > 0 && n >= a.maxWatchers {
+			return ErrTooManyWatchers
+		}
+		if atomic.CompareAndSwapInt64(&a.watcherCount, n, n+1) {
+			return nil
+		}
+	}
+}
+
+func (a *AntsDB) releaseWatcher() {
+	atomic.AddInt64(&a.watcherCount, -1)
+}
@@ -0,0 +1,65 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMaxWatchers(t *testing.T) {
+	adb, _ := makeTestingHost(t, WithMaxWatchers(2))
+	defer adb.Close()
+
+	routines := adb.ResourceStats().Goroutines
+
+	ctx1, cancel1 := context.WithCancel(context.TODO())
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.TODO())
+	defer cancel2()
+
+	events, err := adb.Events(ctx1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = adb.WatchWhere(ctx2, func(string, []byte) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := adb.WatcherCount(); n != 2 {
+		t.Fatal("incorrect watcher count", n)
+	}
+	_, err = adb.Writers(context.TODO())
+	if err != ErrTooManyWatchers {
+		t.Fatal("expected ErrTooManyWatchers", err)
+	}
+	_, err = adb.Events(context.TODO())
+	if err != ErrTooManyWatchers {
+		t.Fatal("expected ErrTooManyWatchers", err)
+	}
+
+	cancel1()
+	// Closed once the watcher is gone
+	for range events {
+	}
+	if n := adb.WatcherCount(); n != 1 {
+		t.Fatal("incorrect watcher count after cancel", n)
+	}
+
+	ctx3, cancel3 := context.WithCancel(context.TODO())
+	writers, err := adb.Writers(ctx3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel2()
+	cancel3()
+	for range writers {
+	}
+
+	started := time.Now()
+	for adb.WatcherCount() != 0 || adb.ResourceStats().Goroutines != routines {
+		if time.Since(started) > time.Second {
+			t.Fatal("watchers not freed", adb.WatcherCount(), adb.ResourceStats().Goroutines, routines)
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,169 @@
+package antsdb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	cid "github.com/ipfs/go-cid"
+	pb "github.com/ipfs/go-ds-crdt/pb"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+var seenHeadsCacheSize = 4096
+
+// writers attributes DAG heads to the peer which first broadcasted them.
+// Replicas keep rebroadcasting heads they hold, but only the replica which
+// created a delta can be the first to announce its CID.
+type writers struct {
+	mtx    sync.Mutex
+	seen   *lru.Cache
+	known  map[peer.ID]struct{}
+	order  []peer.ID
+	notify []chan struct{}
+}
+
+func newWriters() *writers {
+	seen, _ := lru.New(seenHeadsCacheSize)
+	return &writers{
+		seen:  seen,
+		known: make(map[peer.ID]struct{}),
+	}
+}
+
+func decodeHeads(data []byte) []cid.Cid {
+	bcast := pb.CRDTBroadcast{}
+	err := proto.Unmarshal(data, &bcast)
+	if err != nil {
+		return nil
+	}
+	heads := make([]cid.Cid, 0, len(bcast.Heads))
+	for _, h := range bcast.Heads {
+		c, err := cid.Cast(h.Cid)
+		if err != nil {
+			continue
+		}
+		heads = append(heads, c)
+	}
+	return heads
+}
+
+func (a *AntsDB) observeHeads(data []byte, from peer.ID) {
+	if from != a.host.ID() {
+		atomic.StoreInt64(&a.lastRemote, time.Now().UnixNano())
+	}
+	for _, c := range decodeHeads(data) {
+		if a.writers.attribute(c, from) {
+			log.Debugf("Head %s attributed to %s", c, from)
+		}
+	}
+}
+
+// attribute returns true if the head was not seen before
+func (w *writers) attribute(c cid.Cid, from peer.ID) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if found, _ := w.seen.ContainsOrAdd(c, from); found {
+		return false
+	}
+	if _, found := w.known[from]; !found {
+		w.known[from] = struct{}{}
+		w.order = append(w.order, from)
+		for _, n := range w.notify {
+			select {
+			case n <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return true
+}
+
+func (w *writers) heads() []cid.Cid {
+	keys := w.seen.Keys()
+	heads := make([]cid.Cid, 0, len(keys))
+	for _, k := range keys {
+		heads = append(heads, k.(cid.Cid))
+	}
+	return heads
+}
+
+func (w *writers) subscribe() chan struct{} {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	n := make(chan struct{}, 1)
+	w.notify = append(w.notify, n)
+	return n
+}
+
+func (w *writers) unsubscribe(n chan struct{}) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for i, v := range w.notify {
+		if v == n {
+			w.notify = append(w.notify[:i], w.notify[i+1:]...)
+			return
+		}
+	}
+}
+
+func (w *writers) since(idx int) []peer.ID {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return append([]peer.ID(nil), w.order[idx:]...)
+}
+
+// Writers emits the peers seen originating deltas, starting with the ones
+// already known. A peer is attributed a delta when it is the first one this
+// node hears announcing the delta's CID, so peers which only read and
+// rebroadcast heads never show up. The attribution is only as good as the
+// broadcasts this node receives: if it missed the original announcement of
+// a delta, the first peer rebroadcasting it is credited instead. The channel
+// is closed once ctx is done or AntsDB is closed.
+func (a *AntsDB) Writers(ctx context.Context) (<-chan peer.ID, error) {
+	if a.ctx.Err() != nil {
+		return nil, a.ctx.Err()
+	}
+	if err := a.acquireWatcher(); err != nil {
+		return nil, err
+	}
+	n := a.writers.subscribe()
+	// Emit whatever is already known
+	n <- struct{}{}
+
+	res := make(chan peer.ID)
+	a.spawn(func() {
+		defer close(res)
+		defer a.releaseWatcher()
+		defer a.writers.unsubscribe(n)
+
+		idx := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.ctx.Done():
+				return
+			case <-n:
+			}
+			for _, p := range a.writers.since(idx) {
+				select {
+				case res <- p:
+					idx++
+				case <-ctx.Done():
+					return
+				case <-a.ctx.Done():
+					return
+				}
+			}
+		}
+	})
+	return res, nil
+}
@@ -0,0 +1,66 @@
+package antsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestWriters(t *testing.T) {
+	adb1, h1 := makeTestingHost(t)
+	defer adb1.Close()
+
+	adb2, h2 := makeTestingHost(t)
+	defer adb2.Close()
+
+	adb3, h3 := makeTestingHost(t)
+	defer adb3.Close()
+
+	connectHosts(t, h1, h2, h3)
+	// Allow pubsub mesh to form
+	<-time.After(time.Second)
+
+	err := adb1.Put(context.TODO(), "key1", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	writers, err := adb3.Writers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = adb2.Put(context.TODO(), "key2", []byte("val"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[peer.ID]bool{}
+	for len(seen) < 2 {
+		select {
+		case p := <-writers:
+			seen[p] = true
+		case <-ctx.Done():
+			t.Fatal("writers not seen", seen)
+		}
+	}
+	if !seen[h1.ID()] || !seen[h2.ID()] {
+		t.Fatal("incorrect writers", seen)
+	}
+
+	// Reader should never show up even after rebroadcasts
+	select {
+	case p := <-writers:
+		t.Fatal("unexpected writer", p, p == h3.ID())
+	case <-time.After(2 * time.Second):
+	}
+
+	cancel()
+	for range writers {
+	}
+}